@@ -1,37 +1,119 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/kloudyuk/gitter/pkg/alert"
+	"github.com/kloudyuk/gitter/pkg/demo"
+	"github.com/kloudyuk/gitter/pkg/export"
+	"github.com/kloudyuk/gitter/pkg/git"
+	"github.com/kloudyuk/gitter/pkg/history"
+	"github.com/kloudyuk/gitter/pkg/hooks"
+	"github.com/kloudyuk/gitter/pkg/logging"
+	"github.com/kloudyuk/gitter/pkg/runner"
+	"github.com/kloudyuk/gitter/pkg/statsd"
+	"github.com/kloudyuk/gitter/pkg/telemetry"
 	"github.com/kloudyuk/gitter/pkg/ui"
+	"github.com/kloudyuk/gitter/pkg/workload"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 const (
 	// Input validation constants
 	MinWidth = 50
 	MaxWidth = 300
+
+	// SSHPassphraseEnv holds the passphrase for --ssh-key, kept out of flags
+	// so it doesn't end up in shell history or process listings
+	SSHPassphraseEnv = "GITTER_SSH_KEY_PASSPHRASE"
+
+	// hooksCloseTimeout bounds waiting for hook events still queued on exit
+	hooksCloseTimeout = 5 * time.Second
 )
 
-func init() {
-	rootCmd.AddCommand(cloneCmd())
+func cloneCmd() *cobra.Command {
+	return newCloneCmd(runner.DefaultRegistry)
 }
 
-func cloneCmd() *cobra.Command {
+// newCloneCmd creates the clone command for the operations in registry
+func newCloneCmd(registry *runner.Registry) *cobra.Command {
 	flags := struct {
-		interval     time.Duration
-		timeout      time.Duration
-		width        int
-		demo         bool
-		errorHistory int
+		interval      time.Duration
+		timeout       time.Duration
+		width         int
+		demo          bool
+		errorHistory  int
+		logFile       string
+		logAppend     bool
+		logMaxSize    int
+		logMaxBackups int
+		logLevel      string
+		logFormat     string
+		seed          uint64
+		scenario      string
+		sshKey        string
+		knownHosts    string
+		hostKeyPolicy string
+		caFile        string
+		clientCert    string
+		clientKey     string
+		insecureTLS   bool
+		certExpiry    time.Duration
+		proxy         string
+		headers       []string
+		resolve       []string
+		roundRobin    bool
+		ipFamily      string
+		retries       int
+		retryBackoff  time.Duration
+		retryMax      time.Duration
+		retryOn       []string
+		loadProfile   string
+		maxInFlight   int
+		workload      string
+		pushRef       string
+		headless      bool
+		op            string
+		webhooks      []string
+		webhookFormat string
+		onOutage      string
+		outageAfter   int
+		alerts        []string
+		historyDir    string
+		noHistory     bool
+		historyKeep   int
+		exports       []string
+		failureBudget float64
+		otlpEndpoint  string
+		otlpProtocol  string
+		statsdAddr    string
+		statsdFormat  string
+		statsdPrefix  string
+		statsdTags    []string
 	}{}
 	cmd := &cobra.Command{
 		Use:   "clone URL",
 		Short: "Clone a git repo repeatedly to check stability",
 		Long: `Clone a git repository repeatedly to test its stability and reliability.
-Use the --demo flag to run in simulation mode without actually cloning repositories.`,
+URLs may be https://, http://, ssh:// or scp-style (git@host:org/repo.git).
+Select another registered operation, such as fetch or ls-remote, with --op.
+Use the --demo flag to run in simulation mode without actually cloning repositories.
+Demo runs are reproducible with --seed, and --scenario plays back a JSON file of
+phases (e.g. healthy, outage, slow, flapping) to rehearse dashboards and alerting.
+
+Operations:
+` + operationHelp(registry),
 		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// Validate input parameters
@@ -47,23 +129,490 @@ Use the --demo flag to run in simulation mode without actually cloning repositor
 			if flags.errorHistory <= 0 {
 				return fmt.Errorf("error-history must be positive, got %d", flags.errorHistory)
 			}
+			if flags.logMaxSize < 0 {
+				return fmt.Errorf("log-max-size must not be negative, got %d", flags.logMaxSize)
+			}
+			if flags.logMaxBackups < 0 {
+				return fmt.Errorf("log-max-backups must not be negative, got %d", flags.logMaxBackups)
+			}
+			level, err := logging.ParseLevel(flags.logLevel)
+			if err != nil {
+				return err
+			}
+			if !slices.Contains(logging.Formats, flags.logFormat) {
+				return fmt.Errorf("log-format must be one of %s, got %s", strings.Join(logging.Formats, ", "), flags.logFormat)
+			}
+
+			if !slices.Contains(git.HostKeyPolicies, git.HostKeyPolicy(flags.hostKeyPolicy)) {
+				return fmt.Errorf("host-key-policy must be one of strict, accept-new, insecure, got %s", flags.hostKeyPolicy)
+			}
+			if (flags.clientCert == "") != (flags.clientKey == "") {
+				return fmt.Errorf("client-cert and client-key must be given together")
+			}
+			header, err := parseHeaders(flags.headers)
+			if err != nil {
+				return err
+			}
+			resolve := map[string]string{}
+			for _, r := range flags.resolve {
+				addr, ip, err := git.ParseResolve(r)
+				if err != nil {
+					return err
+				}
+				resolve[addr] = ip
+			}
+			var family git.IPFamily
+			switch flags.ipFamily {
+			case "", "both":
+			case "4", "6":
+				family = git.IPFamily(flags.ipFamily)
+			default:
+				return fmt.Errorf("ip-family must be one of 4, 6, both, got %s", flags.ipFamily)
+			}
+			if flags.retries < 0 {
+				return fmt.Errorf("retries must not be negative, got %d", flags.retries)
+			}
+			if flags.retryBackoff < 0 || flags.retryMax < 0 {
+				return fmt.Errorf("retry-backoff and retry-max-backoff must not be negative")
+			}
+			retryOn := make([]git.ErrorClass, 0, len(flags.retryOn))
+			for _, c := range flags.retryOn {
+				if !slices.Contains(git.ErrorClasses, git.ErrorClass(c)) {
+					return fmt.Errorf("retry-on must only contain known error classes, got %s", c)
+				}
+				retryOn = append(retryOn, git.ErrorClass(c))
+			}
+			var profile runner.LoadProfile
+			if flags.loadProfile != "" {
+				if profile, err = runner.ParseLoadProfile(flags.loadProfile); err != nil {
+					return err
+				}
+			}
+			if flags.historyKeep < 0 {
+				return fmt.Errorf("history-keep must not be negative, got %d", flags.historyKeep)
+			}
+			if flags.maxInFlight < 0 {
+				return fmt.Errorf("max-in-flight must not be negative, got %d", flags.maxInFlight)
+			}
+			if !slices.Contains(hooks.Formats, flags.webhookFormat) {
+				return fmt.Errorf("webhook-format must be one of %s, got %s", strings.Join(hooks.Formats, ", "), flags.webhookFormat)
+			}
+			if flags.outageAfter <= 0 {
+				return fmt.Errorf("outage-after must be positive, got %d", flags.outageAfter)
+			}
+			rules := make([]alert.Rule, 0, len(flags.alerts))
+			for _, a := range flags.alerts {
+				rule, err := alert.ParseRule(a)
+				if err != nil {
+					return err
+				}
+				rules = append(rules, rule)
+			}
+			specs := make([]export.Spec, 0, len(flags.exports))
+			for _, e := range flags.exports {
+				spec, err := export.ParseSpec(e)
+				if err != nil {
+					return err
+				}
+				specs = append(specs, spec)
+			}
+			if flags.failureBudget < 0 || flags.failureBudget > 100 {
+				return fmt.Errorf("failure-budget must be between 0 and 100, got %g", flags.failureBudget)
+			}
+			if !slices.Contains(telemetry.Protocols, flags.otlpProtocol) {
+				return fmt.Errorf("otlp-protocol must be one of %s, got %s", strings.Join(telemetry.Protocols, ", "), flags.otlpProtocol)
+			}
+			if flags.otlpEndpoint != "" {
+				if _, err := telemetry.ParseEndpoint(flags.otlpEndpoint); err != nil {
+					return err
+				}
+			}
+			if !slices.Contains(statsd.Formats, flags.statsdFormat) {
+				return fmt.Errorf("statsd-format must be one of %s, got %s", strings.Join(statsd.Formats, ", "), flags.statsdFormat)
+			}
+			if flags.statsdFormat == statsd.FormatStatsD && len(flags.statsdTags) > 0 {
+				return fmt.Errorf("statsd-tag needs --statsd-format %s, plain StatsD has no tags", statsd.FormatDogStatsD)
+			}
+			if flags.certExpiry < 0 {
+				return fmt.Errorf("cert-expiry-warning must not be negative, got %v", flags.certExpiry)
+			}
+			if _, ok := registry.Lookup(flags.op); !ok {
+				return fmt.Errorf("op must be one of %s, got %s", strings.Join(operationNames(registry), ", "), flags.op)
+			}
+			if flags.op == "exec" {
+				if err := checkExecFlags(cmd.Flags()); err != nil {
+					return err
+				}
+			}
+			if flags.demo && cmd.Flags().Changed("op") && flags.op != "demo" {
+				return fmt.Errorf("--demo can't be combined with --op %s", flags.op)
+			}
+			if flags.op == "demo" {
+				flags.demo = true
+			}
+			if flags.scenario != "" && !flags.demo {
+				return fmt.Errorf("scenario requires --demo")
+			}
+
+			var mix *workload.Workload
+			if flags.workload != "" {
+				if strings.HasSuffix(flags.workload, ".json") {
+					mix, err = workload.Load(flags.workload)
+				} else {
+					mix, err = workload.Parse(flags.workload)
+				}
+				if err != nil {
+					return err
+				}
+			}
 
 			var repoURL string
-			if flags.demo {
+			switch {
+			case flags.demo:
 				repoURL = "https://github.com/demo/repo.git (simulated)"
-			} else {
-				if len(args) == 0 {
-					return fmt.Errorf("repository URL is required when not in demo mode")
-				}
+			case len(args) > 0:
 				repoURL = args[0]
+			case mix != nil && len(mix.Repos) > 0:
+				repoURL = fmt.Sprintf("%d repositories from workload", len(mix.Repos))
+			default:
+				return fmt.Errorf("repository URL is required when not in demo mode")
+			}
+
+			cfg := ui.Config{
+				Repo:         repoURL,
+				Interval:     flags.interval,
+				Timeout:      flags.timeout,
+				Width:        flags.width,
+				DemoMode:     flags.demo,
+				Operation:    flags.op,
+				Operations:   registry,
+				ErrorHistory: flags.errorHistory,
+				Git: git.Options{
+					SSH: git.SSHOptions{
+						KeyFile:       flags.sshKey,
+						KeyPassphrase: os.Getenv(SSHPassphraseEnv),
+						KnownHosts:    flags.knownHosts,
+						HostKeyPolicy: git.HostKeyPolicy(flags.hostKeyPolicy),
+					},
+					TLS: git.TLSOptions{
+						CAFile:             flags.caFile,
+						ClientCert:         flags.clientCert,
+						ClientKey:          flags.clientKey,
+						InsecureSkipVerify: flags.insecureTLS,
+					},
+					Proxy:      flags.proxy,
+					Header:     header,
+					Resolve:    resolve,
+					RoundRobin: flags.roundRobin,
+					IPFamily:   family,
+					PushRef:    flags.pushRef,
+				},
+				Workload:    mix,
+				DualStack:   flags.ipFamily == "both",
+				LoadProfile: profile,
+				MaxInFlight: flags.maxInFlight,
+				Retry: runner.RetryPolicy{
+					MaxRetries:     flags.retries,
+					InitialBackoff: flags.retryBackoff,
+					MaxBackoff:     flags.retryMax,
+					Classes:        retryOn,
+				},
+				CertExpiryWarning: flags.certExpiry,
+				Alerts:            rules,
+			}
+
+			if flags.demo {
+				cfg.Seed = flags.seed
+				if !cmd.Flags().Changed("seed") {
+					cfg.Seed = rand.Uint64()
+				}
+				if flags.scenario != "" {
+					if cfg.Scenario, err = demo.LoadScenario(flags.scenario); err != nil {
+						return err
+					}
+				}
+			}
+
+			// Only log in demo mode if a log file was explicitly requested
+			if !flags.demo || cmd.Flags().Changed("log-file") {
+				f, err := logging.OpenRotatingFile(flags.logFile, flags.logAppend, int64(flags.logMaxSize)*1024*1024, flags.logMaxBackups)
+				if err != nil {
+					return err
+				}
+				defer func() {
+					if closeErr := f.Close(); closeErr != nil {
+						// Log close error, but don't override main error
+						_, _ = fmt.Fprintf(os.Stderr, "Warning: failed to close log file: %v\n", closeErr)
+					}
+				}()
+				cfg.Log, err = logging.New(f, flags.logFormat, level)
+				if err != nil {
+					return err
+				}
+			}
+
+			var notifiers []hooks.Notifier
+			for _, url := range flags.webhooks {
+				notifiers = append(notifiers, &hooks.Webhook{URL: url, Format: flags.webhookFormat})
+			}
+			if flags.onOutage != "" {
+				notifiers = append(notifiers, &hooks.Command{Command: flags.onOutage})
+			}
+			if len(notifiers) > 0 {
+				cfg.Hooks = hooks.NewDispatcher(git.RedactURL(repoURL), flags.outageAfter, cfg.Log, notifiers...)
+				defer func() {
+					ctx, cancel := context.WithTimeout(context.Background(), hooksCloseTimeout)
+					defer cancel()
+					if closeErr := cfg.Hooks.Close(ctx); closeErr != nil {
+						_, _ = fmt.Fprintf(os.Stderr, "Warning: failed to deliver hook events: %v\n", closeErr)
+					}
+				}()
+			}
+
+			op := flags.op
+			if flags.demo {
+				op = "demo"
 			}
-			return ui.Start(repoURL, flags.interval, flags.timeout, flags.width, flags.demo, flags.errorHistory)
+
+			// Like logging, demo runs are only recorded if asked for explicitly
+			if !flags.noHistory && (!flags.demo || cmd.Flags().Changed("history-dir")) {
+				dir := flags.historyDir
+				if dir == "" {
+					if dir, err = history.DefaultDir(); err != nil {
+						return err
+					}
+				}
+				cfg.History, err = history.NewRecorder(dir, history.Run{
+					Target:    git.RedactURL(repoURL),
+					Operation: op,
+					Config:    runConfig(cmd),
+				})
+				if err != nil {
+					return err
+				}
+				if _, err := history.Prune(dir, flags.historyKeep); err != nil {
+					_, _ = fmt.Fprintf(os.Stderr, "Warning: failed to remove old runs: %v\n", err)
+				}
+				defer func() {
+					if closeErr := cfg.History.Close(); closeErr != nil {
+						_, _ = fmt.Fprintf(os.Stderr, "Warning: failed to record run: %v\n", closeErr)
+						return
+					}
+					_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Run recorded as %s\n", cfg.History.Run().ID)
+				}()
+			}
+
+			for _, spec := range specs {
+				e, err := export.New(spec, export.Options{
+					Target:        git.RedactURL(repoURL),
+					Operation:     op,
+					FailureBudget: flags.failureBudget,
+				})
+				if err != nil {
+					return err
+				}
+				cfg.Exports = append(cfg.Exports, e)
+				defer func() {
+					if closeErr := e.Close(); closeErr != nil {
+						_, _ = fmt.Fprintf(os.Stderr, "Warning: failed to export %s: %v\n", spec.Format, closeErr)
+					}
+				}()
+			}
+
+			if flags.otlpEndpoint != "" {
+				cfg.Telemetry, err = telemetry.New(context.Background(), telemetry.Options{
+					Endpoint:  flags.otlpEndpoint,
+					Protocol:  flags.otlpProtocol,
+					Target:    git.RedactURL(repoURL),
+					Operation: op,
+					Version:   getVersion(),
+				})
+				if err != nil {
+					return err
+				}
+				defer func() {
+					if closeErr := cfg.Telemetry.Close(); closeErr != nil {
+						_, _ = fmt.Fprintf(os.Stderr, "Warning: failed to send telemetry: %v\n", closeErr)
+					}
+				}()
+			}
+
+			if flags.statsdAddr != "" {
+				cfg.StatsD, err = statsd.New(statsd.Options{
+					Addr:      flags.statsdAddr,
+					Format:    flags.statsdFormat,
+					Prefix:    flags.statsdPrefix,
+					Target:    git.RedactURL(repoURL),
+					Operation: op,
+					Tags:      flags.statsdTags,
+				})
+				if err != nil {
+					return err
+				}
+				defer func() {
+					if closeErr := cfg.StatsD.Close(); closeErr != nil {
+						_, _ = fmt.Fprintf(os.Stderr, "Warning: failed to send StatsD metrics: %v\n", closeErr)
+					}
+				}()
+			}
+
+			if flags.headless {
+				ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+				defer stop()
+				return ui.RunHeadless(ctx, cfg, cmd.OutOrStdout())
+			}
+			return ui.Start(cfg)
 		},
 	}
 	cmd.Flags().DurationVarP(&flags.interval, "interval", "i", 2*time.Second, "interval between clones (must be positive)")
 	cmd.Flags().DurationVarP(&flags.timeout, "timeout", "t", 10*time.Second, "timeout for clone operations (must be positive)")
 	cmd.Flags().IntVarP(&flags.width, "width", "w", 100, fmt.Sprintf("terminal width for display (%d-%d)", MinWidth, MaxWidth))
 	cmd.Flags().BoolVarP(&flags.demo, "demo", "d", false, "run in demo mode with simulated git operations")
-	cmd.Flags().IntVarP(&flags.errorHistory, "error-history", "e", 5, "number of recent errors to display (must be positive)")
+	cmd.Flags().IntVarP(&flags.errorHistory, "error-history", "e", 5, "number of error groups to display (must be positive)")
+	cmd.Flags().Uint64Var(&flags.seed, "seed", 0, "random seed for demo mode (random if not set)")
+	cmd.Flags().StringVar(&flags.scenario, "scenario", "", "JSON scenario file describing demo mode phases")
+	cmd.Flags().StringVar(&flags.sshKey, "ssh-key", "", fmt.Sprintf("private key for ssh URLs (uses the ssh agent if empty, passphrase from $%s)", SSHPassphraseEnv))
+	cmd.Flags().StringVar(&flags.knownHosts, "known-hosts", "", "known_hosts file for ssh URLs (default ~/.ssh/known_hosts)")
+	cmd.Flags().StringVar(&flags.hostKeyPolicy, "host-key-policy", string(git.HostKeyStrict), "ssh host key checking (strict, accept-new, insecure)")
+	cmd.Flags().StringVar(&flags.caFile, "ca-file", "", "PEM file of CA certificates to trust for https URLs, in addition to the system roots")
+	cmd.Flags().StringVar(&flags.clientCert, "client-cert", "", "PEM client certificate for https URLs requiring mutual TLS")
+	cmd.Flags().StringVar(&flags.clientKey, "client-key", "", "PEM private key for --client-cert")
+	cmd.Flags().BoolVar(&flags.insecureTLS, "insecure-skip-tls-verify", false, "don't verify the server certificate for https URLs")
+	cmd.Flags().DurationVar(&flags.certExpiry, "cert-expiry-warning", ui.DefaultCertExpiryWarning, "warn when the server certificate expires within this time (0 disables)")
+	cmd.Flags().StringVar(&flags.proxy, "proxy", "", "proxy URL, overriding HTTP_PROXY, HTTPS_PROXY and NO_PROXY (ssh URLs need socks5://)")
+	cmd.Flags().StringArrayVarP(&flags.headers, "header", "H", nil, `extra HTTP header sent on every request, as "Name: value" (repeatable)`)
+	cmd.Flags().StringArrayVar(&flags.resolve, "resolve", nil, "connect to IP instead of looking up host:port, as host:port:ip (repeatable)")
+	cmd.Flags().BoolVar(&flags.roundRobin, "round-robin", false, "resolve every A/AAAA record for the host and spread clones across them")
+	cmd.Flags().StringVar(&flags.ipFamily, "ip-family", "", "connect over IPv4 (4), IPv6 (6) or alternate between them (both); default lets the system choose")
+	cmd.Flags().StringVar(&flags.loadProfile, "load-profile", "", "vary the rate of clones instead of using --interval, e.g. ramp,from=1,to=10,over=5m (see README)")
+	cmd.Flags().IntVar(&flags.maxInFlight, "max-in-flight", 500, "most clones a --load-profile runs at once, skipping starts beyond it (0 for no limit)")
+	cmd.Flags().StringVar(&flags.workload, "workload", "", "mix of operations to run, inline (ls-remote=70,clone=25,full-clone=5) or a .json file with repositories (see README)")
+	cmd.Flags().StringVar(&flags.pushRef, "push-ref", git.DefaultPushRef, "branch the push operation overwrites")
+	cmd.Flags().IntVar(&flags.retries, "retries", 0, "retry a failed clone up to this many times before counting it as failed")
+	cmd.Flags().DurationVar(&flags.retryBackoff, "retry-backoff", time.Second, "delay before the first retry, doubling for each retry after that (jittered)")
+	cmd.Flags().DurationVar(&flags.retryMax, "retry-max-backoff", 30*time.Second, "maximum delay between retries")
+	cmd.Flags().StringSliceVar(&flags.retryOn, "retry-on", classNames(runner.DefaultRetryClasses), "error classes to retry")
+	cmd.Flags().StringVar(&flags.op, "op", runner.DefaultOperation, fmt.Sprintf("operation to run (%s)", strings.Join(operationNames(registry), ", ")))
+	for _, op := range registry.Operations() {
+		if op.Flags != nil {
+			op.Flags(cmd.Flags())
+		}
+	}
+	cmd.Flags().StringArrayVar(&flags.webhooks, "webhook", nil, "URL to POST outage and threshold events to (repeatable)")
+	cmd.Flags().StringVar(&flags.webhookFormat, "webhook-format", hooks.FormatJSON, "webhook payload format (json, slack, teams)")
+	cmd.Flags().StringVar(&flags.onOutage, "on-outage", "", "shell command run on outage and threshold events, with details in GITTER_* environment variables")
+	cmd.Flags().IntVar(&flags.outageAfter, "outage-after", hooks.DefaultOutageAfter, "consecutive failures that start an outage")
+	cmd.Flags().StringVar(&flags.historyDir, "history-dir", "", "directory runs are recorded in (default $XDG_DATA_HOME/gitter/runs or ~/.local/share/gitter/runs)")
+	cmd.Flags().BoolVar(&flags.noHistory, "no-history", false, "don't record the run")
+	cmd.Flags().IntVar(&flags.historyKeep, "history-keep", history.DefaultKeep, "number of recorded runs to keep, removing the oldest (0 keeps every run)")
+	cmd.Flags().StringArrayVar(&flags.exports, "export", nil, "write the results at the end of the run, as csv=path or junit=path (repeatable)")
+	cmd.Flags().Float64Var(&flags.failureBudget, "failure-budget", export.DefaultFailureBudget, "percentage of attempts that may fail before a JUnit test case fails")
+	cmd.Flags().StringVar(&flags.otlpEndpoint, "otlp-endpoint", "", "OpenTelemetry collector URL to send traces and metrics to, e.g. http://localhost:4317")
+	cmd.Flags().StringVar(&flags.otlpProtocol, "otlp-protocol", telemetry.ProtocolGRPC, "OTLP protocol (grpc, http)")
+	cmd.Flags().StringVar(&flags.statsdAddr, "statsd", "", "StatsD agent host:port to send metrics to over UDP, e.g. 127.0.0.1:8125")
+	cmd.Flags().StringVar(&flags.statsdFormat, "statsd-format", statsd.FormatDogStatsD, "StatsD format (dogstatsd, statsd)")
+	cmd.Flags().StringVar(&flags.statsdPrefix, "statsd-prefix", statsd.DefaultPrefix, "prefix for StatsD metric names")
+	cmd.Flags().StringArrayVar(&flags.statsdTags, "statsd-tag", nil, "key:value tag added to every StatsD metric (repeatable)")
+	cmd.Flags().StringArrayVar(&flags.alerts, "alert", nil, "alert threshold, e.g. failure-rate,warn=10,crit=25,window=5m (repeatable, see README)")
+	cmd.Flags().BoolVar(&flags.headless, "headless", false, "print a line per attempt instead of the terminal UI, with a summary on interrupt")
+	cmd.Flags().StringVar(&flags.logFile, "log-file", "gitter.log", "path of the log file (demo mode only logs when this is set)")
+	cmd.Flags().BoolVar(&flags.logAppend, "log-append", false, "append to the log file instead of truncating it")
+	cmd.Flags().IntVar(&flags.logMaxSize, "log-max-size", 0, "rotate the log file after this many MB (0 disables rotation)")
+	cmd.Flags().IntVar(&flags.logMaxBackups, "log-max-backups", 3, "number of rotated log files to keep")
+	cmd.Flags().StringVar(&flags.logLevel, "log-level", "info", "minimum log level (debug, info, warn, error)")
+	cmd.Flags().StringVar(&flags.logFormat, "log-format", "text", "log record format (text, json)")
 	return cmd
 }
+
+// connectionFlags configure go-git's connection, so have no effect on the
+// command run by --op exec
+var connectionFlags = []string{
+	"ssh-key", "known-hosts", "host-key-policy", "ca-file", "client-cert", "client-key",
+	"insecure-skip-tls-verify", "proxy", "header", "resolve", "round-robin", "ip-family",
+}
+
+// checkExecFlags rejects flags that --op exec would silently ignore
+func checkExecFlags(fs *pflag.FlagSet) error {
+	for _, name := range connectionFlags {
+		if fs.Changed(name) {
+			return fmt.Errorf("--op exec can't be combined with --%s; configure the command given by --exec-command instead", name)
+		}
+	}
+	if fs.Changed("workload") {
+		return fmt.Errorf("--op exec can't be combined with --workload")
+	}
+	return nil
+}
+
+// operationNames lists the registered operations for help and errors
+func operationNames(registry *runner.Registry) []string {
+	ops := registry.Operations()
+	names := make([]string, len(ops))
+	for i, op := range ops {
+		names[i] = op.Name
+	}
+	return names
+}
+
+// operationHelp lists the registered operations with their descriptions,
+// one per line
+func operationHelp(registry *runner.Registry) string {
+	ops := registry.Operations()
+	width := 0
+	for _, op := range ops {
+		width = max(width, len(op.Name))
+	}
+	lines := make([]string, len(ops))
+	for i, op := range ops {
+		lines[i] = strings.TrimRight(fmt.Sprintf("  %-*s  %s", width, op.Name, op.Description), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// classNames converts error classes to strings for flag defaults
+func classNames(classes []git.ErrorClass) []string {
+	names := make([]string, len(classes))
+	for i, c := range classes {
+		names[i] = string(c)
+	}
+	return names
+}
+
+// parseHeaders converts "Name: value" flag values to an http.Header
+func parseHeaders(values []string) (http.Header, error) {
+	header := http.Header{}
+	for _, v := range values {
+		name, value, ok := strings.Cut(v, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("header must be in the form \"Name: value\", got %q", v)
+		}
+		header.Add(name, strings.TrimSpace(value))
+	}
+	return header, nil
+}
+
+// runConfig records the flags set on cmd for the run history, leaving out
+// header values and webhook URLs as they often hold credentials
+func runConfig(cmd *cobra.Command) map[string]string {
+	config := map[string]string{}
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		switch f.Name {
+		case "header":
+			values, _ := cmd.Flags().GetStringArray(f.Name)
+			names := make([]string, len(values))
+			for i, v := range values {
+				name, _, _ := strings.Cut(v, ":")
+				names[i] = strings.TrimSpace(name) + ": <redacted>"
+			}
+			config[f.Name] = strings.Join(names, ", ")
+		case "webhook":
+			config[f.Name] = "<redacted>"
+		case "proxy", "otlp-endpoint":
+			config[f.Name] = git.RedactURL(f.Value.String())
+		default:
+			config[f.Name] = f.Value.String()
+		}
+	})
+	return config
+}
@@ -2,9 +2,14 @@ package cmd
 
 import (
 	"errors"
+	"slices"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/kloudyuk/gitter/pkg/runner"
+
+	"github.com/spf13/pflag"
 )
 
 func TestCloneCommandValidation(t *testing.T) {
@@ -328,3 +333,97 @@ t.Errorf("Got unexpected validation error: %v", validationErr)
 })
 }
 }
+
+func TestParseHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    map[string][]string
+		wantErr bool
+	}{
+		{"none", nil, map[string][]string{}, false},
+		{"single", []string{"X-Trace-Id: abc"}, map[string][]string{"X-Trace-Id": {"abc"}}, false},
+		{"repeated", []string{"Cookie: a=1", "cookie: b=2"}, map[string][]string{"Cookie": {"a=1", "b=2"}}, false},
+		{"empty value", []string{"X-Empty:"}, map[string][]string{"X-Empty": {""}}, false},
+		{"missing colon", []string{"X-Trace-Id abc"}, nil, true},
+		{"missing name", []string{": abc"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseHeaders(tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %v, got %v", tt.want, got)
+			}
+			for name, values := range tt.want {
+				if !slices.Equal(got[name], values) {
+					t.Errorf("Expected %s to be %v, got %v", name, values, got[name])
+				}
+			}
+		})
+	}
+}
+
+func TestOperationFlags(t *testing.T) {
+	registry := runner.NewRegistry()
+	registry.Register(runner.Registration{
+		Name:        "test-flags",
+		Description: "call an endpoint before cloning",
+		Flags:       func(fs *pflag.FlagSet) { fs.String("test-flags-endpoint", "", "endpoint to call first") },
+		New: func(cfg runner.OperationConfig) (runner.CloneOperation, error) {
+			return &runner.DemoCloneOperation{}, nil
+		},
+	})
+
+	cmd := newCloneCmd(registry)
+	if err := cmd.ParseFlags([]string{"--op", "test-flags", "--test-flags-endpoint", "https://api.example.com"}); err != nil {
+		t.Fatalf("Expected operation flags to be accepted, got %v", err)
+	}
+	if got := cmd.Flag("test-flags-endpoint").Value.String(); got != "https://api.example.com" {
+		t.Errorf("Expected endpoint flag to be set, got '%s'", got)
+	}
+	if usage := cmd.Flag("op").Usage; !strings.Contains(usage, "test-flags") {
+		t.Errorf("Expected --op usage to list registered operations, got '%s'", usage)
+	}
+	if !strings.Contains(cmd.Long, "test-flags  call an endpoint before cloning") {
+		t.Errorf("Expected help to describe registered operations, got '%s'", cmd.Long)
+	}
+	if _, ok := runner.LookupOperation("test-flags"); ok {
+		t.Error("Expected test operation not to be added to the default registry")
+	}
+}
+
+func TestExecOperationFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"proxy", []string{"--proxy", "http://proxy:3128"}, "--op exec can't be combined with --proxy"},
+		{"header", []string{"-H", "X-Test: 1"}, "--op exec can't be combined with --header"},
+		{"resolve", []string{"--resolve", "git.example.com:443:10.0.0.1"}, "--op exec can't be combined with --resolve"},
+		{"ip family", []string{"--ip-family", "6"}, "--op exec can't be combined with --ip-family"},
+		{"ca file", []string{"--ca-file", "ca.pem"}, "--op exec can't be combined with --ca-file"},
+		{"ssh key", []string{"--ssh-key", "id_ed25519"}, "--op exec can't be combined with --ssh-key"},
+		{"workload", []string{"--workload", "clone=1"}, "--op exec can't be combined with --workload"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := cloneCmd()
+			cmd.SetArgs(append([]string{"https://git.example.com/repo.git", "--op", "exec"}, tt.args...))
+			cmd.SilenceUsage = true
+			cmd.SilenceErrors = true
+			err := cmd.Execute()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing '%s', got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kloudyuk/gitter/pkg/chaos"
+	"github.com/kloudyuk/gitter/pkg/jsontime"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(proxyCmd())
+}
+
+func proxyCmd() *cobra.Command {
+	flags := struct {
+		listen       string
+		upstream     string
+		latency      time.Duration
+		jitter       time.Duration
+		bandwidth    int64
+		errorRate    float64
+		errorStatus  int
+		resetRate    float64
+		truncateRate float64
+		schedule     string
+		seed         uint64
+	}{}
+	cmd := &cobra.Command{
+		Use:   "proxy",
+		Short: "Forward git HTTP traffic while injecting faults",
+		Long: `Run a reverse proxy in front of a git HTTP server that injects latency,
+bandwidth limits, connection resets, truncated responses and HTTP 5xx errors,
+either at fixed rates or following a JSON schedule. Point gitter clone at the
+proxy to exercise the whole pipeline locally, e.g.
+
+  gitter serve-fixture --listen 127.0.0.1:8081 &
+  gitter proxy --listen 127.0.0.1:8080 --upstream http://127.0.0.1:8081 --error-rate 0.2 &
+  gitter clone http://127.0.0.1:8080/fixture.git`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flags.upstream == "" {
+				return fmt.Errorf("upstream is required")
+			}
+			upstream, err := url.Parse(flags.upstream)
+			if err != nil || upstream.Scheme == "" || upstream.Host == "" {
+				return fmt.Errorf("upstream must be an absolute http(s) URL, got %q", flags.upstream)
+			}
+
+			var schedule *chaos.Schedule
+			if flags.schedule != "" {
+				if schedule, err = chaos.LoadSchedule(flags.schedule); err != nil {
+					return err
+				}
+			} else {
+				faults := chaos.Faults{
+					Latency:      jsontime.Duration(flags.latency),
+					Jitter:       jsontime.Duration(flags.jitter),
+					Bandwidth:    flags.bandwidth,
+					ErrorRate:    flags.errorRate,
+					ErrorStatus:  flags.errorStatus,
+					ResetRate:    flags.resetRate,
+					TruncateRate: flags.truncateRate,
+				}
+				if err := faults.Validate(); err != nil {
+					return err
+				}
+				schedule = chaos.Constant(faults)
+			}
+
+			seed := flags.seed
+			if !cmd.Flags().Changed("seed") {
+				seed = rand.Uint64()
+			}
+
+			ln, err := net.Listen("tcp", flags.listen)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Proxying http://%s to %s (seed %d)\n", ln.Addr(), upstream, seed)
+
+			log := slog.New(slog.NewTextHandler(os.Stderr, nil))
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return serve(ctx, &http.Server{Handler: chaos.New(upstream, schedule, seed, log)}, ln)
+		},
+	}
+	cmd.Flags().StringVarP(&flags.listen, "listen", "l", "127.0.0.1:8080", "address to listen on")
+	cmd.Flags().StringVarP(&flags.upstream, "upstream", "u", "", "upstream git server base URL (required)")
+	cmd.Flags().DurationVar(&flags.latency, "latency", 0, "latency added to every request")
+	cmd.Flags().DurationVar(&flags.jitter, "jitter", 0, "random extra latency of up to this duration")
+	cmd.Flags().Int64Var(&flags.bandwidth, "bandwidth", 0, "response bandwidth limit in bytes per second (0 is unlimited)")
+	cmd.Flags().Float64Var(&flags.errorRate, "error-rate", 0, "fraction of requests answered with an HTTP 5xx error (0-1)")
+	cmd.Flags().IntVar(&flags.errorStatus, "error-status", 503, "HTTP status returned for injected errors (5xx)")
+	cmd.Flags().Float64Var(&flags.resetRate, "reset-rate", 0, "fraction of requests whose connection is reset (0-1)")
+	cmd.Flags().Float64Var(&flags.truncateRate, "truncate-rate", 0, "fraction of responses cut off part way through (0-1)")
+	cmd.Flags().StringVar(&flags.schedule, "schedule", "", "JSON schedule of fault steps (overrides the rate flags)")
+	cmd.Flags().Uint64Var(&flags.seed, "seed", 0, "random seed for fault injection (random if not set)")
+	return cmd
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/kloudyuk/gitter/pkg/history"
+	"github.com/kloudyuk/gitter/pkg/hooks"
+	"github.com/kloudyuk/gitter/pkg/report"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(reportCmd())
+}
+
+func reportCmd() *cobra.Command {
+	flags := struct {
+		format      string
+		output      string
+		historyDir  string
+		buckets     int
+		outageAfter int
+	}{}
+	cmd := &cobra.Command{
+		Use:   "report RUN",
+		Short: "Render a recorded run as an HTML or Markdown report",
+		Long: `Render a recorded run as a report to attach to a change ticket, with a
+timeline of outcomes, attempt duration over time, outages, a breakdown of
+error classes and the configuration used. HTML reports are a single file with
+inline charts; Markdown reports use tables instead.
+
+RUN is a run ID (or enough of the start of it to pick out one run), a run
+directory, or a results.ndjson file, e.g.
+
+  gitter report 20261019-090000-3f2a -o upgrade.html
+  gitter report ./runs/20261019-090000-3f2a/results.ndjson --format markdown`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format := flags.format
+			if format == "" {
+				format = report.FormatHTML
+				if ext := filepath.Ext(flags.output); ext == ".md" || ext == ".markdown" {
+					format = report.FormatMarkdown
+				}
+			}
+			if !slices.Contains(report.Formats, format) {
+				return fmt.Errorf("format must be one of %s, got %s", strings.Join(report.Formats, ", "), format)
+			}
+			if flags.buckets <= 0 {
+				return fmt.Errorf("buckets must be positive, got %d", flags.buckets)
+			}
+			if flags.outageAfter <= 0 {
+				return fmt.Errorf("outage-after must be positive, got %d", flags.outageAfter)
+			}
+
+			run, results, err := loadRun(args[0], flags.historyDir)
+			if err != nil {
+				return err
+			}
+			r := report.New(run, results, report.Options{Buckets: flags.buckets, OutageAfter: flags.outageAfter})
+
+			if flags.output == "" {
+				return r.Write(cmd.OutOrStdout(), format)
+			}
+			f, err := os.Create(flags.output)
+			if err != nil {
+				return err
+			}
+			if err := r.Write(f, format); err != nil {
+				_ = f.Close()
+				return err
+			}
+			return f.Close()
+		},
+	}
+	cmd.Flags().StringVarP(&flags.format, "format", "f", "", "report format (html, markdown); default html unless --output ends in .md")
+	cmd.Flags().StringVarP(&flags.output, "output", "o", "", "file to write the report to (default stdout)")
+	cmd.Flags().StringVar(&flags.historyDir, "history-dir", "", "directory runs are recorded in (default $XDG_DATA_HOME/gitter/runs or ~/.local/share/gitter/runs)")
+	cmd.Flags().IntVar(&flags.buckets, "buckets", report.DefaultBuckets, "number of slices of time the timeline is split into")
+	cmd.Flags().IntVar(&flags.outageAfter, "outage-after", hooks.DefaultOutageAfter, "consecutive failures that start an outage")
+	return cmd
+}
+
+// loadRun reads the run and results named by arg: a results file, a run
+// directory or the ID of a run in the history
+func loadRun(arg, dir string) (history.Run, []history.Result, error) {
+	info, err := os.Stat(arg)
+	switch {
+	case err == nil && !info.IsDir():
+		// A bare results file, described by the run.json beside it if any
+		f, err := os.Open(arg)
+		if err != nil {
+			return history.Run{}, nil, err
+		}
+		defer func() { _ = f.Close() }()
+		results, err := history.ReadResults(f)
+		if err != nil {
+			return history.Run{}, nil, fmt.Errorf("reading %s: %w", arg, err)
+		}
+		if filepath.Base(arg) != history.ResultsFile {
+			return history.Run{}, results, nil
+		}
+		run, err := history.Load(filepath.Dir(arg))
+		if errors.Is(err, fs.ErrNotExist) {
+			return history.Run{}, results, nil
+		}
+		return run, results, err
+	case err == nil:
+		run, err := history.Load(arg)
+		if err != nil {
+			return history.Run{}, nil, err
+		}
+		results, err := history.LoadResults(run)
+		return run, results, err
+	}
+
+	root, err := historyDir(dir)
+	if err != nil {
+		return history.Run{}, nil, err
+	}
+	run, err := history.Find(root, arg)
+	if err != nil {
+		return history.Run{}, nil, err
+	}
+	results, err := history.LoadResults(run)
+	return run, results, err
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kloudyuk/gitter/pkg/history"
+)
+
+func TestLoadRun(t *testing.T) {
+	dir := t.TempDir()
+	run := history.Run{ID: "20261019-090000-aaaa", Target: "https://git.example.com/repo.git", Start: time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)}
+	if err := writeTestRun(dir, run, 0); err != nil {
+		t.Fatalf("Failed to record run: %v", err)
+	}
+	runDir := filepath.Join(dir, run.ID)
+	bare := filepath.Join(t.TempDir(), "copy.ndjson")
+	b, err := os.ReadFile(filepath.Join(runDir, history.ResultsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bare, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		arg    string
+		target string
+	}{
+		{"run id", "20261019", run.Target},
+		{"run directory", runDir, run.Target},
+		{"results file", filepath.Join(runDir, history.ResultsFile), run.Target},
+		{"bare results file", bare, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, results, err := loadRun(tt.arg, dir)
+			if err != nil {
+				t.Fatalf("Failed to load run: %v", err)
+			}
+			if got.Target != tt.target {
+				t.Errorf("Expected target '%s', got '%s'", tt.target, got.Target)
+			}
+			if len(results) != 4 {
+				t.Errorf("Expected 4 results, got %d", len(results))
+			}
+		})
+	}
+}
+
+func TestReportCommand(t *testing.T) {
+	dir := t.TempDir()
+	run := history.Run{ID: "20261019-090000-aaaa", Target: "https://git.example.com/repo.git", Start: time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)}
+	if err := writeTestRun(dir, run, 0); err != nil {
+		t.Fatalf("Failed to record run: %v", err)
+	}
+
+	out := filepath.Join(t.TempDir(), "report.md")
+	execute(t, reportCmd(), run.ID, "--history-dir", dir, "-o", out)
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+	if !strings.HasPrefix(string(b), "# Gitter report: https://git.example.com/repo.git") {
+		t.Errorf("Expected a Markdown report for a .md output, got:\n%s", b)
+	}
+
+	if html := execute(t, reportCmd(), run.ID, "--history-dir", dir); !strings.HasPrefix(html, "<!DOCTYPE html>") {
+		t.Errorf("Expected an HTML report by default, got:\n%s", html)
+	}
+}
@@ -3,6 +3,8 @@ package cmd
 import (
 	"os"
 
+	"github.com/kloudyuk/gitter/pkg/git"
+
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
@@ -20,6 +22,12 @@ var rootCmd = &cobra.Command{
 }
 
 func Execute() {
+	// Clones pick their http transport, and with it the TLS, proxy, header
+	// and address settings, per run rather than from go-git's defaults
+	git.InstallTransport()
+	// The clone command is added here rather than in init so operations
+	// registered by other packages' init functions get their flags
+	rootCmd.AddCommand(cloneCmd())
 	if err := rootCmd.Execute(); err != nil {
 		_, _ = red.Fprintf(os.Stderr, "ERROR: %s\n", err)
 		os.Exit(1)
@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kloudyuk/gitter/pkg/history"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(runsCmd())
+	rootCmd.AddCommand(compareCmd())
+}
+
+func runsCmd() *cobra.Command {
+	var dir string
+	cmd := &cobra.Command{
+		Use:   "runs",
+		Short: "List and inspect recorded runs",
+		Long: `Every clone run is recorded with its configuration, a line per attempt and a
+summary, so it can be looked at again or compared with another run later.`,
+	}
+	cmd.PersistentFlags().StringVar(&dir, "history-dir", "", "directory runs are recorded in (default $XDG_DATA_HOME/gitter/runs or ~/.local/share/gitter/runs)")
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List recorded runs, oldest first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root, err := historyDir(dir)
+			if err != nil {
+				return err
+			}
+			runs, err := history.List(root)
+			if err != nil {
+				return err
+			}
+			if len(runs) == 0 {
+				_, err := fmt.Fprintf(cmd.OutOrStdout(), "No runs recorded in %s\n", root)
+				return err
+			}
+			return writeRuns(cmd.OutOrStdout(), runs)
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "show RUN",
+		Short: "Show a recorded run's configuration and results",
+		Long:  `Show a recorded run. RUN is its ID, or enough of the start of it to pick out one run.`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root, err := historyDir(dir)
+			if err != nil {
+				return err
+			}
+			run, err := history.Find(root, args[0])
+			if err != nil {
+				return err
+			}
+			return writeRun(cmd.OutOrStdout(), run)
+		},
+	})
+
+	var keep int
+	prune := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove all but the newest recorded runs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if keep <= 0 {
+				return fmt.Errorf("keep must be positive, got %d", keep)
+			}
+			root, err := historyDir(dir)
+			if err != nil {
+				return err
+			}
+			removed, err := history.Prune(root, keep)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "Removed %d runs from %s\n", len(removed), root)
+			return err
+		},
+	}
+	prune.Flags().IntVar(&keep, "keep", history.DefaultKeep, "number of the newest runs to keep")
+	cmd.AddCommand(prune)
+	return cmd
+}
+
+func compareCmd() *cobra.Command {
+	var dir string
+	cmd := &cobra.Command{
+		Use:   "compare RUN_A RUN_B",
+		Short: "Compare two recorded runs",
+		Long: `Compare two recorded runs, e.g. a soak test before and after an upgrade,
+showing the change in success rate, throughput, duration percentiles and
+error classes from RUN_A to RUN_B, and any configuration that differs.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root, err := historyDir(dir)
+			if err != nil {
+				return err
+			}
+			a, err := history.Find(root, args[0])
+			if err != nil {
+				return err
+			}
+			b, err := history.Find(root, args[1])
+			if err != nil {
+				return err
+			}
+			return writeComparison(cmd.OutOrStdout(), a, b)
+		},
+	}
+	cmd.Flags().StringVar(&dir, "history-dir", "", "directory runs are recorded in (default $XDG_DATA_HOME/gitter/runs or ~/.local/share/gitter/runs)")
+	return cmd
+}
+
+// historyDir returns dir, or the default history directory if it's empty
+func historyDir(dir string) (string, error) {
+	if dir != "" {
+		return dir, nil
+	}
+	return history.DefaultDir()
+}
+
+// writeRuns writes a line for each run
+func writeRuns(w io.Writer, runs []history.Run) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ID\tSTARTED\tDURATION\tOPERATION\tATTEMPTS\tSUCCESS\tTARGET")
+	for _, run := range runs {
+		duration, attempts, success := "running", "-", "-"
+		if s := run.Summary; s != nil {
+			duration = s.Elapsed.Round(time.Second).String()
+			attempts = strconv.Itoa(s.Attempts())
+			success = fmt.Sprintf("%.1f%%", s.SuccessRate())
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			run.ID, run.Start.Format(time.DateTime), duration, run.Operation, attempts, success, run.Target)
+	}
+	return tw.Flush()
+}
+
+// writeRun describes a run in full
+func writeRun(w io.Writer, run history.Run) error {
+	s := run.Summary
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(tw, "ID\t%s\n", run.ID)
+	_, _ = fmt.Fprintf(tw, "Target\t%s\n", run.Target)
+	_, _ = fmt.Fprintf(tw, "Operation\t%s\n", run.Operation)
+	_, _ = fmt.Fprintf(tw, "Started\t%s\n", run.Start.Format(time.DateTime))
+	if run.End.IsZero() {
+		_, _ = fmt.Fprintf(tw, "Ended\tnever (%s recorded)\n", s.Elapsed.Round(time.Second))
+	} else {
+		_, _ = fmt.Fprintf(tw, "Ended\t%s (%s)\n", run.End.Format(time.DateTime), s.Elapsed.Round(time.Second))
+	}
+	_, _ = fmt.Fprintf(tw, "Attempts\t%d (%d succeeded, %d failed)\n", s.Attempts(), s.Success, s.Fail)
+	_, _ = fmt.Fprintf(tw, "Success\t%.1f%%\n", s.SuccessRate())
+	_, _ = fmt.Fprintf(tw, "Throughput\t%.2f/s\n", s.Throughput())
+	_, _ = fmt.Fprintf(tw, "Duration\tp50 %s  p90 %s  p99 %s\n",
+		s.P50.Round(time.Millisecond), s.P90.Round(time.Millisecond), s.P99.Round(time.Millisecond))
+
+	if len(run.Config) > 0 {
+		_, _ = fmt.Fprintln(tw, "\nConfig")
+		for _, name := range slices.Sorted(maps.Keys(run.Config)) {
+			_, _ = fmt.Fprintf(tw, "  %s\t%s\n", name, run.Config[name])
+		}
+	}
+	if len(s.Classes) > 0 {
+		_, _ = fmt.Fprintln(tw, "\nError classes")
+		for _, class := range slices.Sorted(maps.Keys(s.Classes)) {
+			_, _ = fmt.Fprintf(tw, "  %s\t%d\n", class, s.Classes[class])
+		}
+	}
+	return tw.Flush()
+}
+
+// writeComparison shows how run b differs from run a
+func writeComparison(w io.Writer, a, b history.Run) error {
+	sa, sb := a.Summary, b.Summary
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(tw, "\t%s\t%s\tCHANGE\n", a.ID, b.ID)
+	_, _ = fmt.Fprintf(tw, "Started\t%s\t%s\n", a.Start.Format(time.DateTime), b.Start.Format(time.DateTime))
+	_, _ = fmt.Fprintf(tw, "Elapsed\t%s\t%s\n", sa.Elapsed.Round(time.Second), sb.Elapsed.Round(time.Second))
+	_, _ = fmt.Fprintf(tw, "Attempts\t%d\t%d\t%+d\n", sa.Attempts(), sb.Attempts(), sb.Attempts()-sa.Attempts())
+	_, _ = fmt.Fprintf(tw, "Success rate\t%.1f%%\t%.1f%%\t%+.1fpp\n", sa.SuccessRate(), sb.SuccessRate(), change(sa.SuccessRate(), sb.SuccessRate(), 1))
+	_, _ = fmt.Fprintf(tw, "Throughput\t%.2f/s\t%.2f/s\t%+.2f/s\n", sa.Throughput(), sb.Throughput(), change(sa.Throughput(), sb.Throughput(), 2))
+	for _, p := range []struct {
+		name string
+		a, b time.Duration
+	}{
+		{"p50", sa.P50, sb.P50},
+		{"p90", sa.P90, sb.P90},
+		{"p99", sa.P99, sb.P99},
+	} {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", p.name, p.a.Round(time.Millisecond), p.b.Round(time.Millisecond), durationChange(p.a, p.b))
+	}
+
+	classes := slices.Sorted(maps.Keys(sa.Classes))
+	for class := range sb.Classes {
+		if _, ok := sa.Classes[class]; !ok {
+			classes = append(classes, class)
+		}
+	}
+	if len(classes) > 0 {
+		slices.Sort(classes)
+		_, _ = fmt.Fprintln(tw, "\nError classes")
+		for _, class := range classes {
+			ca, cb := sa.Classes[class], sb.Classes[class]
+			_, _ = fmt.Fprintf(tw, "  %s\t%d\t%d\t%+d\n", class, ca, cb, cb-ca)
+		}
+	}
+
+	names := slices.Collect(maps.Keys(a.Config))
+	for name := range b.Config {
+		if _, ok := a.Config[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	var differs bool
+	for _, name := range names {
+		va, okA := a.Config[name]
+		vb, okB := b.Config[name]
+		if okA == okB && va == vb {
+			continue
+		}
+		if !differs {
+			_, _ = fmt.Fprintln(tw, "\nConfig differences")
+			differs = true
+		}
+		_, _ = fmt.Fprintf(tw, "  %s\t%s\t%s\n", name, configValue(va, okA), configValue(vb, okB))
+	}
+	if a.Target != b.Target {
+		_, _ = fmt.Fprintf(tw, "\nTarget\t%s\t%s\n", a.Target, b.Target)
+	}
+	return tw.Flush()
+}
+
+// change returns b - a rounded to the given decimal places, so a tiny
+// decrease isn't shown as -0.00
+func change(a, b float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	d := math.Round((b-a)*scale) / scale
+	if d == 0 {
+		return 0
+	}
+	return d
+}
+
+// durationChange describes the change from a to b, absolute and relative
+func durationChange(a, b time.Duration) string {
+	delta := (b - a).Round(time.Millisecond)
+	sign := "+"
+	if delta < 0 {
+		sign = ""
+	}
+	if a == 0 {
+		return sign + delta.String()
+	}
+	return fmt.Sprintf("%s%s (%+.1f%%)", sign, delta, float64(b-a)/float64(a)*100)
+}
+
+// configValue shows a flag's value, or that it was left at its default
+func configValue(v string, set bool) string {
+	if !set {
+		return "(default)"
+	}
+	return v
+}
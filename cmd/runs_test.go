@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kloudyuk/gitter/pkg/git"
+	"github.com/kloudyuk/gitter/pkg/history"
+	"github.com/kloudyuk/gitter/pkg/runner"
+
+	"github.com/spf13/cobra"
+)
+
+func TestRunsCommands(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 10, 12, 9, 0, 0, 0, time.UTC)
+	for i, run := range []history.Run{
+		{ID: "20261012-090000-aaaa", Target: "https://git.example.com/repo.git", Operation: "clone", Start: start, Config: map[string]string{"interval": "1s"}},
+		{ID: "20261019-090000-bbbb", Target: "https://git.example.com/repo.git", Operation: "clone", Start: start.AddDate(0, 0, 7), Config: map[string]string{"interval": "500ms"}},
+	} {
+		if err := writeTestRun(dir, run, i); err != nil {
+			t.Fatalf("Failed to record run: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		cmd  func() string
+		want []string
+	}{
+		{
+			name: "list",
+			cmd:  func() string { return execute(t, runsCmd(), "list", "--history-dir", dir) },
+			want: []string{"20261012-090000-aaaa  2026-10-12 09:00:00", "20261019-090000-bbbb", "https://git.example.com/repo.git"},
+		},
+		{
+			name: "show",
+			cmd:  func() string { return execute(t, runsCmd(), "show", "20261019", "--history-dir", dir) },
+			want: []string{"ID          20261019-090000-bbbb", "Attempts    4 (2 succeeded, 2 failed)", "interval  500ms", "timeout  2"},
+		},
+		{
+			name: "compare",
+			cmd:  func() string { return execute(t, compareCmd(), "20261012", "20261019", "--history-dir", dir) },
+			want: []string{
+				"Success rate  75.0%",
+				"50.0%",
+				"-25.0pp",
+				"p50           1s",
+				"+1s (+100.0%)",
+				"timeout  1  2  +1",
+				"interval  1s  500ms",
+			},
+		},
+		{
+			name: "prune",
+			cmd: func() string {
+				return execute(t, runsCmd(), "prune", "--keep", "1", "--history-dir", dir) + execute(t, runsCmd(), "list", "--history-dir", dir)
+			},
+			want: []string{"Removed 1 runs", "20261019-090000-bbbb"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := tt.cmd()
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("Expected output to contain '%s', got:\n%s", want, out)
+				}
+			}
+			if tt.name == "prune" && strings.Contains(out, "20261012-090000-aaaa") {
+				t.Errorf("Expected the oldest run to be removed, got:\n%s", out)
+			}
+		})
+	}
+}
+
+// writeTestRun records 4 attempts for run, index+1 of them failing, each
+// taking index+1 seconds
+func writeTestRun(dir string, run history.Run, index int) error {
+	rec, err := history.NewRecorder(dir, run)
+	if err != nil {
+		return err
+	}
+	for i := range 4 {
+		a := runner.Attempt{ID: i + 1, Start: run.Start.Add(time.Duration(i) * time.Second), Duration: time.Duration(index+1) * time.Second}
+		if i <= index {
+			a.Err, a.Class = errors.New("i/o timeout"), git.ClassTimeout
+		}
+		rec.Record(a)
+	}
+	return rec.Close()
+}
+
+// execute runs cmd with args, returning its output
+func execute(t *testing.T, cmd *cobra.Command, args ...string) string {
+	t.Helper()
+	var out strings.Builder
+	cmd.SetArgs(args)
+	cmd.SetOut(&out)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Expected %v to succeed, got %v", args, err)
+	}
+	return out.String()
+}
+
+func TestRunConfig(t *testing.T) {
+	cmd := cloneCmd()
+	if err := cmd.ParseFlags([]string{"--interval", "5s", "-H", "Authorization: Bearer abc", "--webhook", "https://hooks.example.com/secret"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	config := runConfig(cmd)
+	want := map[string]string{
+		"interval": "5s",
+		"header":   "Authorization: <redacted>",
+		"webhook":  "<redacted>",
+	}
+	if len(config) != len(want) {
+		t.Errorf("Expected only the flags that were set, got %v", config)
+	}
+	for name, value := range want {
+		if config[name] != value {
+			t.Errorf("Expected %s to be '%s', got '%s'", name, value, config[name])
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/kloudyuk/gitter/pkg/gittest"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(serveFixtureCmd())
+}
+
+func serveFixtureCmd() *cobra.Command {
+	flags := struct {
+		listen  string
+		dir     string
+		commits int
+	}{}
+	cmd := &cobra.Command{
+		Use:   "serve-fixture",
+		Short: "Serve a fixture git repository over smart HTTP",
+		Long: `Serve a generated git repository over the smart HTTP protocol so that
+real-mode clones can be exercised fully offline, e.g.
+
+  gitter serve-fixture --listen 127.0.0.1:8080 &
+  gitter clone http://127.0.0.1:8080/fixture.git`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if flags.commits <= 0 {
+				return fmt.Errorf("commits must be positive, got %d", flags.commits)
+			}
+
+			dir := flags.dir
+			if dir == "" {
+				tmp, err := os.MkdirTemp("", "gitter-fixture-")
+				if err != nil {
+					return err
+				}
+				defer func() { _ = os.RemoveAll(tmp) }()
+				dir = tmp
+			}
+			repo, err := gittest.CreateRepo(dir, flags.commits)
+			if err != nil {
+				return fmt.Errorf("creating fixture repository: %w", err)
+			}
+
+			srv := gittest.NewServer()
+			srv.AddRepo(gittest.FixtureName, repo.Storer)
+
+			ln, err := net.Listen("tcp", flags.listen)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Serving %s with %d commits at http://%s/%s\n", dir, flags.commits, ln.Addr(), gittest.FixtureName)
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return serve(ctx, &http.Server{Handler: srv}, ln)
+		},
+	}
+	cmd.Flags().StringVarP(&flags.listen, "listen", "l", "127.0.0.1:8080", "address to listen on")
+	cmd.Flags().StringVar(&flags.dir, "dir", "", "directory to create the fixture repository in (a temporary directory if empty)")
+	cmd.Flags().IntVar(&flags.commits, "commits", 10, "number of commits in the fixture repository (must be positive)")
+	return cmd
+}
+
+// serve runs srv on ln until ctx is done, then shuts it down
+func serve(ctx context.Context, srv *http.Server, ln net.Listener) error {
+	errC := make(chan error, 1)
+	go func() {
+		errC <- srv.Serve(ln)
+	}()
+	select {
+	case err := <-errC:
+		return err
+	case <-ctx.Done():
+		if err := srv.Shutdown(context.Background()); err != nil {
+			return err
+		}
+		if err := <-errC; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
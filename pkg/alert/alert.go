@@ -0,0 +1,300 @@
+// Package alert judges a run against thresholds, such as the failure rate
+// over the last few minutes, putting it in an OK, WARN or CRIT state.
+package alert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kloudyuk/gitter/pkg/runner"
+)
+
+// DefaultWindow is the span windowed rules look back over unless set
+const DefaultWindow = 5 * time.Minute
+
+// DefaultMinAttempts is how many attempts the window must hold before a
+// failure-rate rule is judged unless set, so a single early failure doesn't
+// count as 100%
+const DefaultMinAttempts = 10
+
+// Level is how bad things are. The zero value means nothing is being judged.
+type Level int
+
+const (
+	None Level = iota
+	OK
+	Warn
+	Crit
+)
+
+func (l Level) String() string {
+	switch l {
+	case OK:
+		return "ok"
+	case Warn:
+		return "warn"
+	case Crit:
+		return "crit"
+	default:
+		return "none"
+	}
+}
+
+// Metric is what a rule measures
+type Metric string
+
+const (
+	// FailureRate is the percentage of attempts failing within the window
+	FailureRate Metric = "failure-rate"
+	// P99 is the 99th percentile attempt duration within the window
+	P99 Metric = "p99"
+	// Consecutive is the number of failures in a row
+	Consecutive Metric = "consecutive"
+)
+
+// Metrics lists the supported metrics
+var Metrics = []Metric{FailureRate, P99, Consecutive}
+
+// Rule raises the level when its metric reaches Warn or Crit. A zero
+// threshold is not checked.
+type Rule struct {
+	Metric Metric
+	Warn   float64
+	Crit   float64
+	// Window is the span FailureRate and P99 are measured over
+	Window time.Duration
+	// MinAttempts is how many attempts the window must hold before
+	// FailureRate is judged; until then the rule stays OK
+	MinAttempts int
+}
+
+func (r Rule) String() string {
+	if r.Metric == Consecutive {
+		return string(r.Metric)
+	}
+	return fmt.Sprintf("%s over %s", r.Metric, r.Window)
+}
+
+// Format renders a value of the rule's metric
+func (r Rule) Format(v float64) string {
+	switch r.Metric {
+	case FailureRate:
+		return fmt.Sprintf("%.1f%%", v)
+	case P99:
+		return time.Duration(v * float64(time.Second)).Round(time.Millisecond).String()
+	default:
+		return strconv.Itoa(int(v))
+	}
+}
+
+// level returns the level a value of the metric reaches
+func (r Rule) level(v float64) Level {
+	switch {
+	case r.Crit > 0 && v >= r.Crit:
+		return Crit
+	case r.Warn > 0 && v >= r.Warn:
+		return Warn
+	default:
+		return OK
+	}
+}
+
+// threshold returns the threshold for level
+func (r Rule) threshold(l Level) float64 {
+	if l == Crit {
+		return r.Crit
+	}
+	return r.Warn
+}
+
+// ParseRule parses a rule such as
+// "failure-rate,warn=10,crit=25,window=5m,min=20", "p99,warn=2s,crit=5s" or
+// "consecutive,crit=5"
+func ParseRule(s string) (Rule, error) {
+	parts := strings.Split(s, ",")
+	r := Rule{Metric: Metric(parts[0])}
+	switch r.Metric {
+	case FailureRate:
+		r.Window = DefaultWindow
+		r.MinAttempts = DefaultMinAttempts
+	case P99:
+		r.Window = DefaultWindow
+	case Consecutive:
+	default:
+		return Rule{}, fmt.Errorf("unknown alert metric %q, must be one of failure-rate, p99, consecutive", parts[0])
+	}
+
+	for _, part := range parts[1:] {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return Rule{}, fmt.Errorf("alert settings must be key=value, got %q", part)
+		}
+		var err error
+		switch key {
+		case "warn":
+			r.Warn, err = r.parseThreshold(value)
+		case "crit":
+			r.Crit, err = r.parseThreshold(value)
+		case "window":
+			if r.Metric == Consecutive {
+				return Rule{}, fmt.Errorf("%s alerts don't take a window", r.Metric)
+			}
+			r.Window, err = time.ParseDuration(value)
+			if err == nil && r.Window <= 0 {
+				err = fmt.Errorf("window must be positive, got %s", value)
+			}
+		case "min":
+			if r.Metric != FailureRate {
+				return Rule{}, fmt.Errorf("%s alerts don't take a minimum", r.Metric)
+			}
+			r.MinAttempts, err = strconv.Atoi(value)
+			if err == nil && r.MinAttempts <= 0 {
+				err = fmt.Errorf("min must be positive, got %s", value)
+			}
+		default:
+			return Rule{}, fmt.Errorf("unknown %s alert setting %q", r.Metric, key)
+		}
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid %s alert: %w", r.Metric, err)
+		}
+	}
+
+	if r.Warn == 0 && r.Crit == 0 {
+		return Rule{}, fmt.Errorf("%s alert needs warn or crit", r.Metric)
+	}
+	if r.Warn > 0 && r.Crit > 0 && r.Warn > r.Crit {
+		return Rule{}, fmt.Errorf("%s alert warn must not be above crit", r.Metric)
+	}
+	return r, nil
+}
+
+// parseThreshold parses a threshold in the metric's units
+func (r Rule) parseThreshold(s string) (float64, error) {
+	var v float64
+	switch r.Metric {
+	case P99:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, err
+		}
+		v = d.Seconds()
+	case Consecutive:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, fmt.Errorf("threshold must be a whole number, got %q", s)
+		}
+		v = float64(n)
+	default:
+		f, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil {
+			return 0, fmt.Errorf("threshold must be a percentage, got %q", s)
+		}
+		v = f
+	}
+	if v <= 0 {
+		return 0, fmt.Errorf("threshold must be positive, got %q", s)
+	}
+	return v, nil
+}
+
+// State is a rule's current judgement
+type State struct {
+	Rule  Rule
+	Level Level
+	// Value is the metric when the level was last evaluated
+	Value float64
+	// Since is when the rule reached its level
+	Since time.Time
+}
+
+// Reason explains why the rule is at its level
+func (s State) Reason() string {
+	if s.Level <= OK {
+		return fmt.Sprintf("%s is %s", s.Rule, s.Rule.Format(s.Value))
+	}
+	return fmt.Sprintf("%s is %s (%s threshold %s)", s.Rule, s.Rule.Format(s.Value), s.Level, s.Rule.Format(s.Rule.threshold(s.Level)))
+}
+
+// Evaluator tracks attempts and keeps every rule's state up to date. It is
+// not safe for concurrent use.
+type Evaluator struct {
+	states      []State
+	windows     map[time.Duration]*runner.Window
+	consecutive int
+}
+
+// NewEvaluator creates an Evaluator for rules, all starting at OK
+func NewEvaluator(rules []Rule) *Evaluator {
+	e := &Evaluator{windows: map[time.Duration]*runner.Window{}}
+	for _, r := range rules {
+		e.states = append(e.states, State{Rule: r, Level: OK})
+		if r.Window > 0 && e.windows[r.Window] == nil {
+			e.windows[r.Window] = runner.NewWindow(r.Window)
+		}
+	}
+	return e
+}
+
+// Observe records an attempt and re-evaluates the rules as of its end,
+// returning the states whose level changed
+func (e *Evaluator) Observe(a runner.Attempt) []State {
+	for _, w := range e.windows {
+		w.Add(a)
+	}
+	if a.Err != nil {
+		e.consecutive++
+	} else {
+		e.consecutive = 0
+	}
+	return e.Evaluate(a.Start.Add(a.Duration))
+}
+
+// Evaluate re-evaluates the rules as of now, so windows recover once
+// failures age out, returning the states whose level changed
+func (e *Evaluator) Evaluate(now time.Time) []State {
+	var changed []State
+	for i := range e.states {
+		s := &e.states[i]
+		var judged bool
+		s.Value, judged = e.value(s.Rule, now)
+		level := OK
+		if judged {
+			level = s.Rule.level(s.Value)
+		}
+		if level != s.Level {
+			s.Level = level
+			s.Since = now
+			changed = append(changed, *s)
+		}
+	}
+	return changed
+}
+
+// value measures the rule's metric as of now, and whether there are enough
+// attempts to judge it
+func (e *Evaluator) value(r Rule, now time.Time) (float64, bool) {
+	if r.Metric == Consecutive {
+		return float64(e.consecutive), true
+	}
+	stats := e.windows[r.Window].Stats(now)
+	if r.Metric == P99 {
+		return stats.P99.Seconds(), true
+	}
+	return stats.FailureRate(), stats.Attempts() >= r.MinAttempts
+}
+
+// States returns the current state of every rule
+func (e *Evaluator) States() []State {
+	return append([]State(nil), e.states...)
+}
+
+// Level returns the worst level of any rule, or None without rules
+func (e *Evaluator) Level() Level {
+	level := None
+	for _, s := range e.states {
+		level = max(level, s.Level)
+	}
+	return level
+}
@@ -0,0 +1,132 @@
+package alert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kloudyuk/gitter/pkg/runner"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Rule
+		wantErr bool
+	}{
+		{"failure-rate,warn=10,crit=25,window=1m", Rule{Metric: FailureRate, Warn: 10, Crit: 25, Window: time.Minute, MinAttempts: DefaultMinAttempts}, false},
+		{"failure-rate,crit=50%", Rule{Metric: FailureRate, Crit: 50, Window: DefaultWindow, MinAttempts: DefaultMinAttempts}, false},
+		{"failure-rate,crit=50,min=3", Rule{Metric: FailureRate, Crit: 50, Window: DefaultWindow, MinAttempts: 3}, false},
+		{"p99,warn=2s,crit=5s", Rule{Metric: P99, Warn: 2, Crit: 5, Window: DefaultWindow}, false},
+		{"consecutive,crit=5", Rule{Metric: Consecutive, Crit: 5}, false},
+		{"latency,warn=1", Rule{}, true},
+		{"failure-rate", Rule{}, true},
+		{"failure-rate,warn=30,crit=20", Rule{}, true},
+		{"failure-rate,warn", Rule{}, true},
+		{"failure-rate,warn=ten", Rule{}, true},
+		{"failure-rate,warn=-1", Rule{}, true},
+		{"failure-rate,warn=10,window=0s", Rule{}, true},
+		{"p99,warn=2", Rule{}, true},
+		{"consecutive,crit=2.5", Rule{}, true},
+		{"consecutive,crit=5,window=1m", Rule{}, true},
+		{"failure-rate,warn=10,level=3", Rule{}, true},
+		{"failure-rate,warn=10,min=0", Rule{}, true},
+		{"failure-rate,warn=10,min=few", Rule{}, true},
+		{"p99,warn=2s,min=5", Rule{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseRule(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEvaluator(t *testing.T) {
+	start := time.Now()
+	failure := errors.New("connection refused")
+	attempt := func(i int, err error) runner.Attempt {
+		return runner.Attempt{ID: i, Start: start.Add(time.Duration(i) * time.Second), Duration: time.Second, Err: err}
+	}
+
+	rate := Rule{Metric: FailureRate, Warn: 20, Crit: 50, Window: time.Minute}
+	streak := Rule{Metric: Consecutive, Crit: 3}
+	e := NewEvaluator([]Rule{rate, streak})
+	if e.Level() != OK {
+		t.Fatalf("Expected rules to start OK, got %s", e.Level())
+	}
+
+	// 1 in 4 failing is a warning
+	var changes []State
+	for i, err := range []error{nil, nil, nil, failure} {
+		changes = append(changes, e.Observe(attempt(i, err))...)
+	}
+	if len(changes) != 1 || changes[0].Rule != rate || changes[0].Level != Warn {
+		t.Fatalf("Expected the failure rate to warn, got %+v", changes)
+	}
+	if want := start.Add(4 * time.Second); !changes[0].Since.Equal(want) {
+		t.Errorf("Expected the warning to start at %s, got %s", want, changes[0].Since)
+	}
+
+	// 3 more in a row is critical for both
+	for i := 4; i < 7; i++ {
+		e.Observe(attempt(i, failure))
+	}
+	if e.Level() != Crit {
+		t.Errorf("Expected crit after 4 failures in a row, got %s", e.Level())
+	}
+	for _, s := range e.States() {
+		if s.Level != Crit {
+			t.Errorf("Expected %s to be crit, got %s", s.Rule, s.Level)
+		}
+	}
+
+	// A success ends the streak but the window still remembers the failures
+	changes = e.Observe(attempt(7, nil))
+	if len(changes) != 1 || changes[0].Rule != streak || changes[0].Level != OK {
+		t.Errorf("Expected only the streak to recover, got %+v", changes)
+	}
+
+	// Once the failures age out of the window it recovers too
+	changes = e.Evaluate(start.Add(2 * time.Minute))
+	if len(changes) != 1 || changes[0].Rule != rate || changes[0].Level != OK {
+		t.Errorf("Expected the failure rate to recover, got %+v", changes)
+	}
+	if e.Level() != OK {
+		t.Errorf("Expected OK once everything recovered, got %s", e.Level())
+	}
+
+	if NewEvaluator(nil).Level() != None {
+		t.Error("Expected no level without rules")
+	}
+}
+
+func TestEvaluatorMinAttempts(t *testing.T) {
+	start := time.Now()
+	rule := Rule{Metric: FailureRate, Warn: 20, Window: time.Minute, MinAttempts: 3}
+	e := NewEvaluator([]Rule{rule})
+
+	// A failure on its own is 100% but too few attempts to judge
+	for i, err := range []error{errors.New("connection refused"), nil} {
+		if changes := e.Observe(runner.Attempt{Start: start.Add(time.Duration(i) * time.Second), Err: err}); len(changes) != 0 {
+			t.Fatalf("Expected no change before %d attempts, got %+v", rule.MinAttempts, changes)
+		}
+	}
+	changes := e.Observe(runner.Attempt{Start: start.Add(2 * time.Second)})
+	if len(changes) != 1 || changes[0].Level != Warn {
+		t.Errorf("Expected a warning once the window holds %d attempts, got %+v", rule.MinAttempts, changes)
+	}
+}
+
+func TestStateReason(t *testing.T) {
+	s := State{Rule: Rule{Metric: P99, Warn: 2, Crit: 5, Window: time.Minute}, Level: Warn, Value: 2.5}
+	if got, want := s.Reason(), "p99 over 1m0s is 2.5s (warn threshold 2s)"; got != want {
+		t.Errorf("Expected '%s', got '%s'", want, got)
+	}
+}
@@ -0,0 +1,335 @@
+// Package chaos implements a reverse proxy for git smart-HTTP traffic that
+// injects latency, bandwidth limits, connection resets, truncated responses
+// and HTTP errors.
+package chaos
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kloudyuk/gitter/pkg/jsontime"
+)
+
+// Fault names used in logs and counters
+const (
+	FaultNone     = "none"
+	FaultError    = "error"
+	FaultReset    = "reset"
+	FaultTruncate = "truncate"
+)
+
+// Faults describes what to inject into proxied requests. Rates are
+// probabilities between 0 and 1; at most one of error, reset and truncate is
+// applied to any request, checked in that order.
+type Faults struct {
+	Latency      jsontime.Duration `json:"latency"`
+	Jitter       jsontime.Duration `json:"jitter"`
+	Bandwidth    int64             `json:"bandwidth"` // response bytes per second, 0 is unlimited
+	ErrorRate    float64           `json:"error_rate"`
+	ErrorStatus  int               `json:"error_status"`
+	ResetRate    float64           `json:"reset_rate"`
+	TruncateRate float64           `json:"truncate_rate"`
+}
+
+// Validate checks the rates and status code are usable
+func (f Faults) Validate() error {
+	for name, rate := range map[string]float64{"error_rate": f.ErrorRate, "reset_rate": f.ResetRate, "truncate_rate": f.TruncateRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s must be between 0 and 1, got %v", name, rate)
+		}
+	}
+	if f.ErrorStatus != 0 && (f.ErrorStatus < 500 || f.ErrorStatus > 599) {
+		return fmt.Errorf("error_status must be a 5xx status, got %d", f.ErrorStatus)
+	}
+	if f.Latency < 0 || f.Jitter < 0 || f.Bandwidth < 0 {
+		return errors.New("latency, jitter and bandwidth must not be negative")
+	}
+	return nil
+}
+
+// Step applies a set of faults for a period of time
+type Step struct {
+	Name     string            `json:"name"`
+	Duration jsontime.Duration `json:"duration"`
+	Faults
+}
+
+// Schedule is a sequence of steps played back in order. The last step
+// continues indefinitely unless Loop is set.
+type Schedule struct {
+	Loop  bool   `json:"loop"`
+	Steps []Step `json:"steps"`
+}
+
+// Constant returns a schedule applying the same faults forever
+func Constant(f Faults) *Schedule {
+	return &Schedule{Steps: []Step{{Name: "constant", Faults: f}}}
+}
+
+// LoadSchedule reads a JSON schedule file
+func LoadSchedule(path string) (*Schedule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Schedule
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parsing schedule %s: %w", path, err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid schedule %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// Validate checks the schedule can be played back
+func (s *Schedule) Validate() error {
+	if len(s.Steps) == 0 {
+		return errors.New("schedule must have at least one step")
+	}
+	for i, step := range s.Steps {
+		if err := step.Faults.Validate(); err != nil {
+			return fmt.Errorf("step %d: %w", i, err)
+		}
+		if step.Duration <= 0 && (i < len(s.Steps)-1 || s.Loop) {
+			return fmt.Errorf("step %d: duration must be positive", i)
+		}
+	}
+	return nil
+}
+
+// stepAt returns the step active at the given time into the schedule
+func (s *Schedule) stepAt(elapsed time.Duration) Step {
+	if s.Loop {
+		var total time.Duration
+		for _, step := range s.Steps {
+			total += time.Duration(step.Duration)
+		}
+		elapsed %= total
+	}
+	for _, step := range s.Steps {
+		if elapsed < time.Duration(step.Duration) {
+			return step
+		}
+		elapsed -= time.Duration(step.Duration)
+	}
+	return s.Steps[len(s.Steps)-1]
+}
+
+// Proxy forwards requests to an upstream git server, injecting faults
+// according to a Schedule
+type Proxy struct {
+	proxy    *httputil.ReverseProxy
+	schedule *Schedule
+	log      *slog.Logger
+
+	mu     sync.Mutex
+	rng    *rand.Rand
+	start  time.Time
+	now    func() time.Time
+	counts map[string]int
+}
+
+// New creates a Proxy for upstream. Request paths are appended to the
+// upstream URL, so with an upstream of https://example.com/org a clone of
+// http://proxy/repo.git fetches https://example.com/org/repo.git. A nil log
+// disables request logging.
+func New(upstream *url.URL, schedule *Schedule, seed uint64, log *slog.Logger) *Proxy {
+	rp := httputil.NewSingleHostReverseProxy(upstream)
+	director := rp.Director
+	rp.Director = func(r *http.Request) {
+		director(r)
+		r.Host = upstream.Host
+	}
+	// Flush as soon as data arrives so bandwidth limits apply smoothly
+	rp.FlushInterval = -1
+
+	return &Proxy{
+		proxy:    rp,
+		schedule: schedule,
+		log:      log,
+		rng:      rand.New(rand.NewPCG(seed, seed)),
+		now:      time.Now,
+		counts:   map[string]int{},
+	}
+}
+
+// Counts returns how many requests each fault has been applied to
+func (p *Proxy) Counts() map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	counts := make(map[string]int, len(p.counts))
+	for k, v := range p.counts {
+		counts[k] = v
+	}
+	return counts
+}
+
+// plan decides which faults apply to the next request
+func (p *Proxy) plan() (Step, string, time.Duration, float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	if p.start.IsZero() {
+		p.start = now
+	}
+	step := p.schedule.stepAt(now.Sub(p.start))
+
+	latency := time.Duration(step.Latency)
+	if step.Jitter > 0 {
+		latency += time.Duration(p.rng.Int64N(int64(step.Jitter)))
+	}
+
+	// Always draw every number so the sequence only depends on the seed
+	errRoll, resetRoll, truncRoll, truncAt := p.rng.Float64(), p.rng.Float64(), p.rng.Float64(), p.rng.Float64()
+	fault := FaultNone
+	switch {
+	case errRoll < step.ErrorRate:
+		fault = FaultError
+	case resetRoll < step.ResetRate:
+		fault = FaultReset
+	case truncRoll < step.TruncateRate:
+		fault = FaultTruncate
+	}
+	p.counts[fault]++
+	return step, fault, latency, truncAt
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	step, fault, latency, truncAt := p.plan()
+	if p.log != nil {
+		p.log.Info("request", "method", r.Method, "path", r.URL.Path, "step", step.Name, "fault", fault, "latency", latency)
+	}
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		select {
+		case <-r.Context().Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+
+	switch fault {
+	case FaultError:
+		status := step.ErrorStatus
+		if status == 0 {
+			status = http.StatusServiceUnavailable
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	case FaultReset:
+		resetConnection(w)
+		return
+	}
+
+	var out http.ResponseWriter = w
+	if step.Bandwidth > 0 {
+		out = &throttledWriter{ResponseWriter: w, rate: step.Bandwidth}
+	}
+	if fault == FaultTruncate {
+		out = &truncatingWriter{ResponseWriter: out, fraction: truncAt}
+	}
+	p.proxy.ServeHTTP(out, r)
+}
+
+// resetConnection closes the client connection without a response, sending a
+// TCP RST where possible
+func resetConnection(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		_ = tcp.SetLinger(0)
+	}
+	_ = conn.Close()
+}
+
+// throttledWriter limits the rate response bytes are written at
+type throttledWriter struct {
+	http.ResponseWriter
+	rate int64
+}
+
+func (t *throttledWriter) Write(b []byte) (int, error) {
+	// Write in chunks of roughly a tenth of a second's worth of data
+	chunk := max(int(t.rate/10), 1)
+	written := 0
+	for written < len(b) {
+		end := min(written+chunk, len(b))
+		n, err := t.ResponseWriter.Write(b[written:end])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		_ = http.NewResponseController(t.ResponseWriter).Flush()
+		time.Sleep(time.Duration(float64(n) / float64(t.rate) * float64(time.Second)))
+	}
+	return written, nil
+}
+
+func (t *throttledWriter) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
+
+// errTruncated is returned once a truncated response reaches its limit,
+// which makes the reverse proxy abort the connection
+var errTruncated = errors.New("chaos: response truncated")
+
+// truncatingWriter cuts the response off part way through the body. The cut
+// point is a fraction of Content-Length when known, otherwise of 64KiB.
+type truncatingWriter struct {
+	http.ResponseWriter
+	fraction    float64
+	limit       int64
+	written     int64
+	wroteHeader bool
+}
+
+func (t *truncatingWriter) WriteHeader(status int) {
+	t.wroteHeader = true
+	size := int64(64 * 1024)
+	if cl := t.Header().Get("Content-Length"); cl != "" {
+		var n int64
+		if _, err := fmt.Sscan(cl, &n); err == nil && n > 0 {
+			size = n
+		}
+	}
+	t.limit = int64(float64(size) * t.fraction)
+	t.ResponseWriter.WriteHeader(status)
+}
+
+func (t *truncatingWriter) Write(b []byte) (int, error) {
+	if !t.wroteHeader {
+		t.WriteHeader(http.StatusOK)
+	}
+	remaining := t.limit - t.written
+	if remaining <= 0 {
+		return 0, errTruncated
+	}
+	if int64(len(b)) > remaining {
+		n, _ := t.ResponseWriter.Write(b[:remaining])
+		t.written += int64(n)
+		return n, errTruncated
+	}
+	n, err := t.ResponseWriter.Write(b)
+	t.written += int64(n)
+	return n, err
+}
+
+func (t *truncatingWriter) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
@@ -0,0 +1,126 @@
+package chaos
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kloudyuk/gitter/pkg/git"
+	"github.com/kloudyuk/gitter/pkg/gittest/gittesttb"
+	"github.com/kloudyuk/gitter/pkg/jsontime"
+)
+
+// startProxy serves a fixture repository through a chaos proxy and returns
+// the proxy and the clone URL that goes through it
+func startProxy(t *testing.T, schedule *Schedule) (*Proxy, string) {
+	t.Helper()
+	repoURL, err := url.Parse(gittesttb.NewTestServer(t, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := repoURL.Path
+	repoURL.Path = ""
+
+	p := New(repoURL, schedule, 1, nil)
+	ts := httptest.NewServer(p)
+	t.Cleanup(ts.Close)
+	return p, ts.URL + name
+}
+
+func TestProxyFaults(t *testing.T) {
+	tests := []struct {
+		name      string
+		faults    Faults
+		wantClass git.ErrorClass
+	}{
+		{"no faults", Faults{}, git.ClassNone},
+		{"http errors", Faults{ErrorRate: 1, ErrorStatus: 502}, git.ClassServer},
+		{"connection resets", Faults{ResetRate: 1}, git.ClassNetwork},
+		{"truncated responses", Faults{TruncateRate: 1}, git.ClassNetwork},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, url := startProxy(t, Constant(tt.faults))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			err := git.Clone(ctx, url)
+
+			if tt.wantClass == git.ClassNone {
+				if err != nil {
+					t.Fatalf("Expected clone through proxy to succeed, got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Expected clone to fail")
+			}
+			if class := git.Classify(err); class != tt.wantClass && !(tt.wantClass == git.ClassNetwork && class == git.ClassProtocol) {
+				t.Errorf("Expected %s error, got %s (%v)", tt.wantClass, class, err)
+			}
+			if p.Counts()[FaultNone] != 0 {
+				t.Errorf("Expected every request to be faulted, got %v", p.Counts())
+			}
+		})
+	}
+}
+
+func TestProxyLatency(t *testing.T) {
+	_, url := startProxy(t, Constant(Faults{Latency: jsontime.Duration(100 * time.Millisecond)}))
+
+	start := time.Now()
+	if err := git.Clone(context.Background(), url); err != nil {
+		t.Fatalf("Clone failed: %v", err)
+	}
+	// A clone makes two requests, each delayed
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("Expected at least 200ms with injected latency, took %s", elapsed)
+	}
+}
+
+func TestScheduleSteps(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schedule.json")
+	content := `{"loop": true, "steps": [
+		{"name": "healthy", "duration": "30s"},
+		{"name": "outage", "duration": "10s", "error_rate": 1, "error_status": 503},
+		{"name": "slow", "duration": "20s", "latency": "2s", "bandwidth": 1024}
+	]}`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	schedule, err := LoadSchedule(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		offset time.Duration
+		want   string
+	}{
+		{0, "healthy"},
+		{35 * time.Second, "outage"},
+		{45 * time.Second, "slow"},
+		{65 * time.Second, "healthy"},
+	}
+	for _, tt := range tests {
+		if got := schedule.stepAt(tt.offset).Name; got != tt.want {
+			t.Errorf("At %s expected step %s, got %s", tt.offset, tt.want, got)
+		}
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"steps": [{"error_rate": 1, "error_status": 404}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSchedule(bad); err == nil || !strings.Contains(err.Error(), "5xx") {
+		t.Errorf("Expected error for non-5xx status, got %v", err)
+	}
+}
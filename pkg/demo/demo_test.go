@@ -2,8 +2,14 @@ package demo
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/kloudyuk/gitter/pkg/jsontime"
 )
 
 func TestDemoClone(t *testing.T) {
@@ -57,3 +63,111 @@ func TestDemoErrorTypes(t *testing.T) {
 		}
 	}
 }
+
+func TestSimulatorDeterministic(t *testing.T) {
+	scenario := &Scenario{Phases: []Phase{{Name: "mixed", SuccessRate: 0.5, MinLatency: jsontime.Duration(time.Millisecond), MaxLatency: jsontime.Duration(time.Second)}}}
+
+	outcomes := func(seed uint64) []string {
+		sim, err := NewSimulator(seed, scenario)
+		if err != nil {
+			t.Fatalf("Failed to create simulator: %v", err)
+		}
+		var got []string
+		for range 20 {
+			latency, err := sim.next()
+			got = append(got, fmt.Sprintf("%s %v", latency, err))
+		}
+		return got
+	}
+
+	a, b := outcomes(42), outcomes(42)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("Expected identical outcomes for the same seed, attempt %d: %s vs %s", i, a[i], b[i])
+		}
+	}
+	if c := outcomes(43); strings.Join(a, ",") == strings.Join(c, ",") {
+		t.Error("Expected different outcomes for a different seed")
+	}
+}
+
+func TestNewSimulatorValidates(t *testing.T) {
+	// A looping scenario with no length would divide by zero in phaseAt
+	scenario := &Scenario{Loop: true, Phases: []Phase{{Name: "empty", SuccessRate: 1}}}
+	if _, err := NewSimulator(1, scenario); err == nil {
+		t.Error("Expected an error for a looping scenario with zero duration")
+	}
+	if _, err := NewSimulator(1, nil); err != nil {
+		t.Errorf("Expected the default scenario to be valid, got %v", err)
+	}
+}
+
+func TestSimulatorPhases(t *testing.T) {
+	scenario := &Scenario{
+		Loop: true,
+		Phases: []Phase{
+			{Name: "healthy", Duration: jsontime.Duration(time.Minute), SuccessRate: 1},
+			{Name: "outage", Duration: jsontime.Duration(20 * time.Second), SuccessRate: 0},
+			{Name: "flapping", Duration: jsontime.Duration(40 * time.Second), SuccessRate: 1, FlapPeriod: jsontime.Duration(10 * time.Second)},
+		},
+	}
+	now := time.Now()
+	sim, err := NewSimulator(1, scenario)
+	if err != nil {
+		t.Fatalf("Failed to create simulator: %v", err)
+	}
+	sim.now = func() time.Time { return now }
+
+	tests := []struct {
+		offset  time.Duration
+		phase   string
+		success bool
+	}{
+		{0, "healthy", true},
+		{59 * time.Second, "healthy", true},
+		{65 * time.Second, "outage", false},
+		{85 * time.Second, "flapping", true},
+		{95 * time.Second, "flapping", false},
+		{105 * time.Second, "flapping", true},
+		{125 * time.Second, "healthy", true}, // looped back to the start
+	}
+
+	start := now
+	for _, tt := range tests {
+		now = start.Add(tt.offset)
+		if got := sim.Phase(); got != tt.phase {
+			t.Errorf("At %s expected phase %s, got %s", tt.offset, tt.phase, got)
+		}
+		if _, err := sim.next(); (err == nil) != tt.success {
+			t.Errorf("At %s expected success %v, got error %v", tt.offset, tt.success, err)
+		}
+	}
+}
+
+func TestLoadScenario(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid.json")
+	content := `{"phases": [
+		{"name": "healthy", "duration": "60s", "success_rate": 0.95, "min_latency": "200ms", "max_latency": "1s"},
+		{"name": "slow", "duration": "30s", "success_rate": 0.9, "min_latency": "5s", "max_latency": "8s"}
+	]}`
+	if err := os.WriteFile(valid, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	scenario, err := LoadScenario(valid)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(scenario.Phases) != 2 || time.Duration(scenario.Phases[1].MinLatency) != 5*time.Second {
+		t.Errorf("Unexpected scenario: %+v", scenario)
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte(`{"phases": [{"name": "bad", "success_rate": 2}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadScenario(invalid); err == nil {
+		t.Error("Expected error for success_rate above 1")
+	}
+}
@@ -0,0 +1,185 @@
+package demo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kloudyuk/gitter/pkg/jsontime"
+)
+
+// Phase describes how the simulated server behaves for a period of time
+type Phase struct {
+	Name        string            `json:"name"`
+	Duration    jsontime.Duration `json:"duration"`
+	SuccessRate float64           `json:"success_rate"`
+	MinLatency  jsontime.Duration `json:"min_latency"`
+	MaxLatency  jsontime.Duration `json:"max_latency"`
+	// FlapPeriod, when set, alternates the phase between its success rate and
+	// a full outage every FlapPeriod
+	FlapPeriod jsontime.Duration `json:"flap_period,omitempty"`
+}
+
+// Scenario is a sequence of phases played back in order. When Loop is set the
+// sequence restarts after the last phase, otherwise the last phase continues
+// indefinitely.
+type Scenario struct {
+	Loop   bool    `json:"loop"`
+	Phases []Phase `json:"phases"`
+}
+
+// DefaultScenario is a single, endless phase matching the package constants
+func DefaultScenario() *Scenario {
+	return &Scenario{
+		Phases: []Phase{{
+			Name:        "healthy",
+			SuccessRate: SuccessRate,
+			MinLatency:  jsontime.Duration(MinCloneTime),
+			MaxLatency:  jsontime.Duration(MaxCloneTime),
+		}},
+	}
+}
+
+// LoadScenario reads a JSON scenario file
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Scenario
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parsing scenario %s: %w", path, err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// Validate checks the scenario can be played back
+func (s *Scenario) Validate() error {
+	if len(s.Phases) == 0 {
+		return errors.New("scenario must have at least one phase")
+	}
+	for i, p := range s.Phases {
+		if p.SuccessRate < 0 || p.SuccessRate > 1 {
+			return fmt.Errorf("phase %d: success_rate must be between 0 and 1, got %v", i, p.SuccessRate)
+		}
+		if p.MinLatency < 0 || p.MaxLatency < p.MinLatency {
+			return fmt.Errorf("phase %d: latency range %s-%s is invalid", i, time.Duration(p.MinLatency), time.Duration(p.MaxLatency))
+		}
+		if p.Duration <= 0 && (i < len(s.Phases)-1 || s.Loop) {
+			return fmt.Errorf("phase %d: duration must be positive", i)
+		}
+		if p.FlapPeriod < 0 {
+			return fmt.Errorf("phase %d: flap_period must not be negative", i)
+		}
+	}
+	return nil
+}
+
+// phaseAt returns the phase active at the given time into the scenario and
+// how far into that phase it is
+func (s *Scenario) phaseAt(elapsed time.Duration) (Phase, time.Duration) {
+	if s.Loop {
+		var total time.Duration
+		for _, p := range s.Phases {
+			total += time.Duration(p.Duration)
+		}
+		elapsed %= total
+	}
+	for _, p := range s.Phases {
+		if elapsed < time.Duration(p.Duration) {
+			return p, elapsed
+		}
+		elapsed -= time.Duration(p.Duration)
+	}
+	last := s.Phases[len(s.Phases)-1]
+	return last, elapsed + time.Duration(last.Duration)
+}
+
+// Simulator plays back a Scenario using a seeded random source so runs with
+// the same seed and scenario produce the same sequence of outcomes
+type Simulator struct {
+	mu       sync.Mutex
+	rng      *rand.Rand
+	scenario *Scenario
+	start    time.Time
+	now      func() time.Time
+}
+
+// NewSimulator creates a Simulator, returning an error if scenario can't be
+// played back. A nil scenario uses DefaultScenario.
+func NewSimulator(seed uint64, scenario *Scenario) (*Simulator, error) {
+	if scenario == nil {
+		scenario = DefaultScenario()
+	}
+	if err := scenario.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario: %w", err)
+	}
+	return &Simulator{
+		rng:      rand.New(rand.NewPCG(seed, seed)),
+		scenario: scenario,
+		now:      time.Now,
+	}, nil
+}
+
+// Phase returns the name of the phase currently being played
+func (s *Simulator) Phase() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, _ := s.scenario.phaseAt(s.elapsed())
+	return p.Name
+}
+
+func (s *Simulator) elapsed() time.Duration {
+	now := s.now()
+	if s.start.IsZero() {
+		s.start = now
+	}
+	return now.Sub(s.start)
+}
+
+// next draws the latency and outcome of the next simulated clone
+func (s *Simulator) next() (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, into := s.scenario.phaseAt(s.elapsed())
+	latency := time.Duration(p.MinLatency)
+	if spread := int64(p.MaxLatency - p.MinLatency); spread > 0 {
+		latency += time.Duration(s.rng.Int64N(spread))
+	}
+
+	rate := p.SuccessRate
+	if p.FlapPeriod > 0 && (into/time.Duration(p.FlapPeriod))%2 == 1 {
+		rate = 0
+	}
+	// Always draw both numbers so the sequence doesn't depend on the outcome
+	roll := s.rng.Float64()
+	demoErr := DemoErrors[s.rng.IntN(len(DemoErrors))]
+	if roll < rate {
+		return latency, nil
+	}
+	return latency, demoErr
+}
+
+// Clone simulates a git clone according to the current scenario phase
+func (s *Simulator) Clone(ctx context.Context, repo string) error {
+	latency, err := s.next()
+
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return err
+	}
+}
@@ -0,0 +1,99 @@
+package export
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kloudyuk/gitter/pkg/git"
+	"github.com/kloudyuk/gitter/pkg/runner"
+)
+
+// csvHeader names the CSV columns. Durations are in milliseconds.
+var csvHeader = []string{
+	"id", "start", "duration_ms", "outcome", "op", "repo", "backend", "ip_family",
+	"retries", "handshake_ms", "transfer_ms", "class", "error",
+}
+
+// CSV writes a row per attempt as it completes
+type CSV struct {
+	mu     sync.Mutex
+	file   *os.File
+	out    *csv.Writer
+	err    error
+	closed bool
+}
+
+// NewCSV creates the CSV file at path and writes its header
+func NewCSV(path string) (*CSV, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating CSV export: %w", err)
+	}
+	c := &CSV{file: f, out: csv.NewWriter(f)}
+	c.err = c.out.Write(csvHeader)
+	return c, nil
+}
+
+// Record writes a row for a
+func (c *CSV) Record(a runner.Attempt) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || c.err != nil {
+		return
+	}
+	c.err = c.out.Write(csvRow(a))
+}
+
+// csvRow returns the columns for a, hiding any credentials in the
+// repository URL
+func csvRow(a runner.Attempt) []string {
+	outcome, errMsg := "ok", ""
+	if a.Err != nil {
+		outcome, errMsg = "fail", a.Err.Error()
+	}
+	family, handshake, transfer := "", "", ""
+	if a.Family != git.IPAny {
+		family = a.Family.String()
+	}
+	if a.Handshake > 0 {
+		handshake, transfer = millis(a.Handshake), millis(a.Transfer)
+	}
+	return []string{
+		strconv.Itoa(a.ID),
+		a.Start.Format(time.RFC3339Nano),
+		millis(a.Duration),
+		outcome,
+		string(a.Op),
+		git.RedactURL(a.Repo),
+		a.Backend,
+		family,
+		strconv.Itoa(a.Retries),
+		handshake,
+		transfer,
+		string(a.Class),
+		errMsg,
+	}
+}
+
+// millis formats d in milliseconds
+func millis(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
+
+// Close flushes the rows and closes the file
+func (c *CSV) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return c.err
+	}
+	c.closed = true
+	c.out.Flush()
+	c.err = errors.Join(c.err, c.out.Error(), c.file.Close())
+	return c.err
+}
@@ -0,0 +1,74 @@
+// Package export writes a run's results in formats other tools read, such as
+// CSV for spreadsheets and QA tooling and JUnit XML for CI servers.
+package export
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kloudyuk/gitter/pkg/runner"
+)
+
+// DefaultFailureBudget is the percentage of attempts that may fail before a
+// JUnit test case fails
+const DefaultFailureBudget = 1.0
+
+const (
+	FormatCSV   = "csv"
+	FormatJUnit = "junit"
+)
+
+// Formats lists the supported export formats
+var Formats = []string{FormatCSV, FormatJUnit}
+
+// Exporter writes attempts to a file. Record may be called concurrently and
+// attempts recorded after Close are ignored.
+type Exporter interface {
+	Record(a runner.Attempt)
+	// Close finishes the file, returning any error hit while writing it
+	Close() error
+}
+
+// Options configures an Exporter
+type Options struct {
+	// Target and Operation name attempts that don't carry their own
+	// repository and operation
+	Target    string
+	Operation string
+	// FailureBudget is the percentage of attempts that may fail before a
+	// JUnit test case fails
+	FailureBudget float64
+}
+
+// Spec is a parsed --export value
+type Spec struct {
+	Format string
+	Path   string
+}
+
+// ParseSpec parses an export such as "csv=results.csv" or
+// "junit=report.xml"
+func ParseSpec(s string) (Spec, error) {
+	format, path, ok := strings.Cut(s, "=")
+	if !ok || path == "" {
+		return Spec{}, fmt.Errorf("export must be format=path, got %q", s)
+	}
+	switch format {
+	case FormatCSV, FormatJUnit:
+	default:
+		return Spec{}, fmt.Errorf("unknown export format %q, must be one of %s", format, strings.Join(Formats, ", "))
+	}
+	return Spec{Format: format, Path: path}, nil
+}
+
+// New creates the exporter for spec
+func New(spec Spec, opts Options) (Exporter, error) {
+	switch spec.Format {
+	case FormatCSV:
+		return NewCSV(spec.Path)
+	case FormatJUnit:
+		return NewJUnit(spec.Path, opts)
+	default:
+		return nil, fmt.Errorf("unknown export format %q", spec.Format)
+	}
+}
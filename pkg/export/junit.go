@@ -0,0 +1,208 @@
+package export
+
+import (
+	"cmp"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kloudyuk/gitter/pkg/git"
+	"github.com/kloudyuk/gitter/pkg/runner"
+)
+
+// JUnit writes a JUnit XML report when it is closed, with a test case for
+// each target and operation that fails if more of its attempts failed than
+// the failure budget allows. A run without any attempts gets a single failing
+// case, so a run that never got going doesn't pass.
+type JUnit struct {
+	file   *os.File
+	opts   Options
+	start  time.Time
+	mu     sync.Mutex
+	cases  map[caseKey]*caseStats
+	closed bool
+	err    error
+}
+
+// caseKey identifies a test case
+type caseKey struct {
+	target    string
+	operation string
+}
+
+// caseStats totals the attempts for a test case
+type caseStats struct {
+	success int
+	fail    int
+	time    time.Duration
+	classes map[string]int
+	// firstErr is the first failure, to give a taste of what went wrong
+	firstErr string
+}
+
+// NewJUnit creates the file at path for a JUnit report, written when the
+// exporter is closed
+func NewJUnit(path string, opts Options) (*JUnit, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating JUnit export: %w", err)
+	}
+	return &JUnit{file: f, opts: opts, start: time.Now(), cases: map[caseKey]*caseStats{}}, nil
+}
+
+// Record adds a to its test case
+func (j *JUnit) Record(a runner.Attempt) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.closed {
+		return
+	}
+	key := caseKey{target: cmp.Or(git.RedactURL(a.Repo), j.opts.Target), operation: cmp.Or(string(a.Op), j.opts.Operation)}
+	c := j.cases[key]
+	if c == nil {
+		c = &caseStats{classes: map[string]int{}}
+		j.cases[key] = c
+	}
+	c.time += a.Duration
+	if a.Err == nil {
+		c.success++
+		return
+	}
+	c.fail++
+	c.classes[string(a.Class)]++
+	if c.firstErr == "" {
+		c.firstErr = a.Err.Error()
+	}
+}
+
+// The JUnit XML schema, as understood by common CI servers
+type (
+	junitSuites struct {
+		XMLName  xml.Name     `xml:"testsuites"`
+		Name     string       `xml:"name,attr"`
+		Tests    int          `xml:"tests,attr"`
+		Failures int          `xml:"failures,attr"`
+		Time     float64      `xml:"time,attr"`
+		Suites   []junitSuite `xml:"testsuite"`
+	}
+	junitSuite struct {
+		Name       string          `xml:"name,attr"`
+		Tests      int             `xml:"tests,attr"`
+		Failures   int             `xml:"failures,attr"`
+		Errors     int             `xml:"errors,attr"`
+		Time       float64         `xml:"time,attr"`
+		Timestamp  string          `xml:"timestamp,attr"`
+		Properties []junitProperty `xml:"properties>property"`
+		Cases      []junitCase     `xml:"testcase"`
+	}
+	junitProperty struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:"value,attr"`
+	}
+	junitCase struct {
+		Name      string        `xml:"name,attr"`
+		ClassName string        `xml:"classname,attr"`
+		Time      float64       `xml:"time,attr"`
+		Failure   *junitFailure `xml:"failure"`
+		SystemOut string        `xml:"system-out,omitempty"`
+	}
+	junitFailure struct {
+		Message string `xml:"message,attr"`
+		Type    string `xml:"type,attr"`
+		Text    string `xml:",chardata"`
+	}
+)
+
+// report builds the JUnit document from the cases recorded so far
+func (j *JUnit) report(end time.Time) junitSuites {
+	elapsed := end.Sub(j.start).Seconds()
+	suite := junitSuite{
+		Name:      "gitter",
+		Time:      elapsed,
+		Timestamp: j.start.Format("2006-01-02T15:04:05"),
+		Properties: []junitProperty{
+			{Name: "failure_budget", Value: fmt.Sprintf("%g%%", j.opts.FailureBudget)},
+		},
+	}
+	keys := slices.SortedFunc(maps.Keys(j.cases), func(a, b caseKey) int {
+		return cmp.Or(cmp.Compare(a.target, b.target), cmp.Compare(a.operation, b.operation))
+	})
+	for _, key := range keys {
+		c := j.cases[key]
+		attempts := c.success + c.fail
+		rate := float64(c.fail) / float64(attempts) * 100
+		tc := junitCase{
+			Name:      key.operation,
+			ClassName: key.target,
+			Time:      c.time.Seconds(),
+			SystemOut: fmt.Sprintf("%d attempts: %d succeeded, %d failed (%.2f%% failure rate, budget %g%%)", attempts, c.success, c.fail, rate, j.opts.FailureBudget),
+		}
+		if rate > j.opts.FailureBudget {
+			tc.Failure = &junitFailure{
+				Message: fmt.Sprintf("%.2f%% of %d attempts failed, over the %g%% failure budget", rate, attempts, j.opts.FailureBudget),
+				Type:    c.worstClass(),
+				Text:    c.breakdown(),
+			}
+			suite.Failures++
+		}
+		suite.Tests++
+		suite.Cases = append(suite.Cases, tc)
+	}
+	if len(keys) == 0 {
+		suite.Cases = append(suite.Cases, junitCase{
+			Name:      cmp.Or(j.opts.Operation, "gitter"),
+			ClassName: j.opts.Target,
+			Failure: &junitFailure{
+				Message: "no attempts completed",
+				Type:    "no_attempts",
+			},
+		})
+		suite.Tests, suite.Failures = 1, 1
+	}
+	return junitSuites{Name: "gitter", Tests: suite.Tests, Failures: suite.Failures, Time: elapsed, Suites: []junitSuite{suite}}
+}
+
+// worstClass returns the most common error class
+func (c *caseStats) worstClass() string {
+	worst, most := "", 0
+	for _, class := range slices.Sorted(maps.Keys(c.classes)) {
+		if n := c.classes[class]; n > most {
+			worst, most = class, n
+		}
+	}
+	return worst
+}
+
+// breakdown lists the failures by error class, and the first error
+func (c *caseStats) breakdown() string {
+	var b strings.Builder
+	for _, class := range slices.Sorted(maps.Keys(c.classes)) {
+		fmt.Fprintf(&b, "%s: %d\n", cmp.Or(class, "unclassified"), c.classes[class])
+	}
+	fmt.Fprintf(&b, "first error: %s\n", c.firstErr)
+	return b.String()
+}
+
+// Close writes the JUnit report
+func (j *JUnit) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.closed {
+		return j.err
+	}
+	j.closed = true
+	b, err := xml.MarshalIndent(j.report(time.Now()), "", "  ")
+	if err == nil {
+		_, err = j.file.Write(append([]byte(xml.Header), append(b, '\n')...))
+	}
+	if err = errors.Join(err, j.file.Close()); err != nil {
+		j.err = fmt.Errorf("writing JUnit export: %w", err)
+	}
+	return j.err
+}
@@ -0,0 +1,113 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrorClass is a coarse category for a failed git operation
+type ErrorClass string
+
+const (
+	ClassNone     ErrorClass = ""
+	ClassTimeout  ErrorClass = "timeout"
+	ClassCanceled ErrorClass = "canceled"
+	ClassDNS      ErrorClass = "dns"
+	ClassRefused  ErrorClass = "refused"
+	ClassNetwork  ErrorClass = "network"
+	ClassTLS      ErrorClass = "tls"
+	ClassHostKey  ErrorClass = "host-key"
+	ClassAuth     ErrorClass = "auth"
+	ClassNotFound ErrorClass = "not-found"
+	ClassServer   ErrorClass = "server"
+	ClassProtocol ErrorClass = "protocol"
+	ClassOther    ErrorClass = "other"
+)
+
+// ErrorClasses lists every class a failed operation can be assigned, in display order
+var ErrorClasses = []ErrorClass{
+	ClassTimeout,
+	ClassCanceled,
+	ClassDNS,
+	ClassRefused,
+	ClassNetwork,
+	ClassTLS,
+	ClassHostKey,
+	ClassAuth,
+	ClassNotFound,
+	ClassServer,
+	ClassProtocol,
+	ClassOther,
+}
+
+// classRules maps lower-cased message fragments to a class. Rules are checked in
+// order, so more specific fragments must come before more general ones.
+var classRules = []struct {
+	fragment string
+	class    ErrorClass
+}{
+	{"internal server error", ClassServer},
+	{"bad gateway", ClassServer},
+	{"service unavailable", ClassServer},
+	{"gateway timeout", ClassServer},
+	{"unexpected client error", ClassServer},
+	// The git CLI reports HTTP failures as "The requested URL returned error: 503"
+	{"returned error: 5", ClassServer},
+	{"returned error: 401", ClassAuth},
+	{"returned error: 403", ClassAuth},
+	{"returned error: 404", ClassNotFound},
+	{"deadline exceeded", ClassTimeout},
+	{"timeout", ClassTimeout},
+	{"timed out", ClassTimeout},
+	{"context canceled", ClassCanceled},
+	{"no such host", ClassDNS},
+	{"could not resolve host", ClassDNS},
+	{"server misbehaving", ClassDNS},
+	{"connection refused", ClassRefused},
+	{"knownhosts", ClassHostKey},
+	{"host key", ClassHostKey},
+	{"x509", ClassTLS},
+	{"tls", ClassTLS},
+	{"ssl", ClassTLS},
+	{"certificate", ClassTLS},
+	{"authentication", ClassAuth},
+	{"authorization", ClassAuth},
+	{"permission denied", ClassAuth},
+	{"unable to authenticate", ClassAuth},
+	{"repository not found", ClassNotFound},
+	{"not found", ClassNotFound},
+	{"connection reset", ClassNetwork},
+	{"broken pipe", ClassNetwork},
+	{"network unreachable", ClassNetwork},
+	{"no route to host", ClassNetwork},
+	{"unexpected eof", ClassNetwork},
+	{"eof", ClassNetwork},
+	{"hung up", ClassProtocol},
+	{"pkt-line", ClassProtocol},
+	{"packfile", ClassProtocol},
+	{"invalid", ClassProtocol},
+}
+
+// Classify assigns an error to an ErrorClass. It works on the error chain where
+// possible and falls back to matching the message, so it also understands
+// errors that only exist as text (such as the simulated demo errors).
+func Classify(err error) ErrorClass {
+	if err == nil {
+		return ClassNone
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ClassTimeout
+	}
+	if errors.Is(err, context.Canceled) {
+		return ClassCanceled
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, rule := range classRules {
+		if strings.Contains(msg, rule.fragment) {
+			return rule.class
+		}
+	}
+	return ClassOther
+}
@@ -2,21 +2,117 @@ package git
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
-	gogit "github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 )
 
+// Options configures how Clone connects to the remote
+type Options struct {
+	SSH SSHOptions
+	TLS TLSOptions
+	// Proxy is the URL of a proxy to use instead of the HTTP_PROXY,
+	// HTTPS_PROXY and NO_PROXY environment variables. ssh URLs only
+	// support socks5 proxies.
+	Proxy string
+	// Header is sent with every http request
+	Header http.Header
+	// Resolve maps host:port addresses to the IP to connect to instead of
+	// looking the host up, see ParseResolve
+	Resolve map[string]string
+	// RoundRobin resolves every A/AAAA record for the host and spreads
+	// clones across them in turn
+	RoundRobin bool
+	// IPFamily restricts http and https clones to IPv4 or IPv6
+	IPFamily IPFamily
+	// PushRef is the branch OpPush overwrites, defaulting to DefaultPushRef
+	PushRef string
+}
+
+// Client clones repositories with a fixed set of Options, reusing HTTP
+// connections between clones
+type Client struct {
+	opts      Options
+	transport http.RoundTripper
+	// caBundle is the CA file passed to go-git's own http clients when
+	// InstallTransport hasn't been called
+	caBundle []byte
+	// next counts clones to pick round-robin backends
+	next atomic.Uint64
+
+	mu      sync.Mutex
+	fetched map[string]*fetchedRepo
+}
+
+// NewClient creates a Client, loading any certificates named in opts. Some
+// options need InstallTransport to have been called first.
+func NewClient(opts Options) (*Client, error) {
+	if err := opts.checkTransport(); err != nil {
+		return nil, err
+	}
+	t, err := newHTTPTransport(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.PushRef == "" {
+		opts.PushRef = DefaultPushRef
+	}
+	c := &Client{opts: opts, transport: t, fetched: map[string]*fetchedRepo{}}
+	if !installed.Load() && opts.TLS.CAFile != "" {
+		// newHTTPTransport has already checked the file holds certificates
+		if c.caBundle, err = os.ReadFile(opts.TLS.CAFile); err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// Clone performs a shallow, in-memory clone of repo using default options
 func Clone(ctx context.Context, repo string) error {
+	return CloneWithOptions(ctx, repo, Options{})
+}
+
+// CloneWithOptions performs a shallow, in-memory clone of repo with a
+// one-off Client
+func CloneWithOptions(ctx context.Context, repo string, opts Options) error {
+	c, err := NewClient(opts)
+	if err != nil {
+		return err
+	}
+	return c.Clone(ctx, repo)
+}
+
+// Clone performs a shallow, in-memory clone of repo. Timings are recorded
+// into any Trace attached to ctx with WithTrace.
+func (c *Client) Clone(ctx context.Context, repo string) error {
+	return c.Run(ctx, OpClone, repo)
+}
+
+// Run performs op against repo. Timings are recorded into any Trace attached
+// to ctx with WithTrace.
+func (c *Client) Run(ctx context.Context, op Operation, repo string) error {
+	start := time.Now()
+	trace := traceFrom(ctx)
+	defer trace.finish(start)
+	ctx = withTransport(WithTrace(ctx, trace), c.transport)
+	ctx = httptrace.WithClientTrace(ctx, clientTrace(trace, start))
+
+	ctx, remote, err := c.connect(ctx, repo, trace, start)
+	if err != nil {
+		return err
+	}
+
 	errC := make(chan error, 1)
 	go func() {
-		_, err := gogit.CloneContext(ctx, memory.NewStorage(), nil, &gogit.CloneOptions{
-			URL:          repo,
-			SingleBranch: true,
-			NoCheckout:   true,
-			Depth:        1,
-		})
-		errC <- err
+		errC <- c.run(ctx, op, repo, remote)
 	}()
 	select {
 	case <-ctx.Done():
@@ -25,3 +121,95 @@ func Clone(ctx context.Context, repo string) error {
 		return err
 	}
 }
+
+// remoteOptions are the go-git settings every operation needs to reach the
+// remote
+type remoteOptions struct {
+	auth  transport.AuthMethod
+	proxy transport.ProxyOptions
+	// caBundle and insecure configure go-git's own http clients
+	caBundle []byte
+	insecure bool
+}
+
+// connect works out how to reach repo, returning a context carrying any
+// pinned addresses
+func (c *Client) connect(ctx context.Context, repo string, trace *Trace, start time.Time) (context.Context, remoteOptions, error) {
+	var remote remoteOptions
+
+	ep, err := transport.NewEndpoint(repo)
+	if err != nil {
+		return nil, remote, err
+	}
+	if c.opts.IPFamily != IPAny {
+		if ep.Protocol == "ssh" {
+			return nil, remote, fmt.Errorf("ip family only supports http and https URLs")
+		}
+		trace.setFamily(c.opts.IPFamily)
+	}
+
+	if c.proxied(ep) {
+		trace.setProxied()
+	}
+
+	pins, err := c.pins(ctx, ep)
+	if err != nil {
+		return nil, remote, err
+	}
+	if len(pins) > 0 {
+		if ep.Protocol == "ssh" {
+			return nil, remote, fmt.Errorf("resolve and round-robin only support http and https URLs")
+		}
+		if ip, ok := pins[endpointAddr(ep)]; ok {
+			trace.setBackend(ip)
+		}
+		ctx = withPins(ctx, pins)
+	}
+
+	if ep.Protocol == "ssh" {
+		if remote.auth, err = sshAuth(ep.User, c.opts.SSH, trace, start); err != nil {
+			return nil, remote, err
+		}
+		if c.opts.Proxy != "" && !strings.HasPrefix(c.opts.Proxy, "socks5") {
+			return nil, remote, fmt.Errorf("ssh URLs only support socks5 proxies, got %s", c.opts.Proxy)
+		}
+	} else if installed.Load() {
+		// The installed transport handles its own proxy, go-git's
+		// ProxyOptions only work when it owns an *http.Transport
+		return ctx, remote, nil
+	} else {
+		remote.caBundle = c.caBundle
+		remote.insecure = c.opts.TLS.InsecureSkipVerify
+	}
+	if c.opts.Proxy != "" {
+		remote.proxy = transport.ProxyOptions{URL: c.opts.Proxy}
+	}
+	return ctx, remote, nil
+}
+
+// proxied reports whether connections to ep go through a proxy, either the
+// one in the options or, for http URLs, one set in the environment
+func (c *Client) proxied(ep *transport.Endpoint) bool {
+	if c.opts.Proxy != "" {
+		return true
+	}
+	if ep.Protocol == "ssh" {
+		return false
+	}
+	u, err := http.ProxyFromEnvironment(&http.Request{URL: &url.URL{Scheme: ep.Protocol, Host: endpointAddr(ep)}})
+	return err == nil && u != nil
+}
+
+// RedactURL hides any password in a repository URL, and the user name of
+// http URLs as it is often a token. Anything that doesn't parse as a URL,
+// such as an scp-style ssh address, is left as it is.
+func RedactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if _, ok := u.User.Password(); !ok && strings.HasPrefix(u.Scheme, "http") {
+		u.User = url.User("xxxxx")
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,327 @@
+package git
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// installed is set once InstallTransport has replaced go-git's http clients
+var installed atomic.Bool
+
+// InstallTransport replaces go-git's http and https clients with one that
+// sends each request with the transport of the Client running it. This is
+// needed for headers, resolve, round-robin, the IP family and client
+// certificates, and to time reference discovery and the pack transfer.
+// Without it Clients use go-git's own clients, passing on the CA file,
+// insecure and proxy settings. It changes go-git's global protocol table, so
+// call it once before running any operations.
+func InstallTransport() {
+	c := githttp.NewClient(&http.Client{Transport: contextTransport{}})
+	client.InstallProtocol("http", c)
+	client.InstallProtocol("https", c)
+	installed.Store(true)
+}
+
+// checkTransport returns an error if opts uses a setting only the transport
+// added by InstallTransport supports and it hasn't been installed
+func (opts Options) checkTransport() error {
+	if installed.Load() {
+		return nil
+	}
+	var setting string
+	switch {
+	case len(opts.Header) > 0:
+		setting = "headers"
+	case len(opts.Resolve) > 0:
+		setting = "resolve"
+	case opts.RoundRobin:
+		setting = "round-robin"
+	case opts.IPFamily != IPAny:
+		setting = "ip family"
+	case opts.TLS.ClientCert != "":
+		setting = "client certificates"
+	default:
+		return nil
+	}
+	return fmt.Errorf("%s need the transport added by git.InstallTransport", setting)
+}
+
+// TLSOptions configures certificate checking and client certificates for
+// https URLs
+type TLSOptions struct {
+	// CAFile is a PEM bundle of CA certificates to trust in addition to the
+	// system roots
+	CAFile string
+	// ClientCert and ClientKey are PEM files used for mutual TLS
+	ClientCert string
+	ClientKey  string
+	// InsecureSkipVerify disables server certificate verification
+	InsecureSkipVerify bool
+}
+
+// tlsConfig builds the TLS configuration for opts, or nil for the defaults
+func (opts TLSOptions) tlsConfig() (*tls.Config, error) {
+	if opts == (TLSOptions{}) {
+		return nil, nil
+	}
+	cfg := &tls.Config{InsecureSkipVerify: opts.InsecureSkipVerify}
+
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", opts.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if (opts.ClientCert == "") != (opts.ClientKey == "") {
+		return nil, errors.New("client certificate and key must be given together")
+	}
+	if opts.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(opts.ClientCert, opts.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// CertInfo describes the certificate a server presented
+type CertInfo struct {
+	Subject     string
+	Issuer      string
+	NotAfter    time.Time
+	Fingerprint string // hex SHA-256 of the DER certificate
+}
+
+func newCertInfo(cert *x509.Certificate) *CertInfo {
+	sum := sha256.Sum256(cert.Raw)
+	return &CertInfo{
+		Subject:     cert.Subject.String(),
+		Issuer:      cert.Issuer.String(),
+		NotAfter:    cert.NotAfter,
+		Fingerprint: hex.EncodeToString(sum[:]),
+	}
+}
+
+// parseProxy checks a proxy URL is one the http and ssh transports can use
+func parseProxy(proxy string) (*url.URL, error) {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %q: scheme must be http, https, socks5 or socks5h", proxy)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: missing host", proxy)
+	}
+	return u, nil
+}
+
+// newHTTPTransport creates the transport used for http and https clones.
+// Without an explicit proxy, HTTP_PROXY, HTTPS_PROXY and NO_PROXY are used.
+func newHTTPTransport(opts Options) (http.RoundTripper, error) {
+	cfg, err := opts.TLS.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyFromEnvironment
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, opts.IPFamily.network(network), pinnedAddr(ctx, addr))
+	}
+	if opts.RoundRobin {
+		// Pooled connections would be reused whichever backend was picked
+		t.DisableKeepAlives = true
+	}
+	if cfg != nil {
+		t.TLSClientConfig = cfg
+	}
+	if opts.Proxy != "" {
+		u, err := parseProxy(opts.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	if len(opts.Header) > 0 {
+		return &headerTransport{base: t, header: opts.Header}, nil
+	}
+	return t, nil
+}
+
+// headerTransport sets extra headers on every request, replacing any value
+// go-git would have sent. A Host header overrides the request's host.
+type headerTransport struct {
+	base   http.RoundTripper
+	header http.Header
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, values := range t.header {
+		if http.CanonicalHeaderKey(name) == "Host" {
+			req.Host = values[0]
+			continue
+		}
+		req.Header[http.CanonicalHeaderKey(name)] = values
+	}
+	return t.base.RoundTrip(req)
+}
+
+type transportKey struct{}
+
+type headerKey struct{}
+
+// WithHeader returns a context whose http requests carry h, such as trace
+// context for the attempt's span. Headers given in Options replace these.
+// It needs the transport added by InstallTransport.
+func WithHeader(ctx context.Context, h http.Header) context.Context {
+	return context.WithValue(ctx, headerKey{}, h)
+}
+
+// HeaderFrom returns the headers attached to ctx by WithHeader
+func HeaderFrom(ctx context.Context) http.Header {
+	h, _ := ctx.Value(headerKey{}).(http.Header)
+	return h
+}
+
+// contextTransport sends requests with the transport and headers attached to
+// their context, timing reference discovery and the pack transfer into the
+// Trace
+type contextTransport struct{}
+
+func (contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	rt, ok := ctx.Value(transportKey{}).(http.RoundTripper)
+	if !ok {
+		rt = http.DefaultTransport
+	}
+
+	trace := traceFrom(ctx)
+	req = req.Clone(ctx)
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	for name, values := range HeaderFrom(ctx) {
+		req.Header[name] = values
+	}
+
+	phase, timed := requestPhase(req)
+	if timed {
+		trace.startPhase(phase)
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		if timed {
+			trace.endPhase(phase)
+		}
+		return resp, err
+	}
+	if timed {
+		resp.Body = &phaseBody{ReadCloser: resp.Body, done: func() { trace.endPhase(phase) }}
+	}
+	return resp, err
+}
+
+// clientTrace records the connection timings, backend and server certificate
+// of http requests into trace, whichever transport sends them
+func clientTrace(trace *Trace, start time.Time) *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { trace.startPhase(PhaseDNS) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { trace.endPhase(PhaseDNS) },
+		ConnectStart: func(_, addr string) {
+			trace.startPhase(PhaseConnect)
+			trace.connectedTo(addr)
+		},
+		ConnectDone:       func(_, _ string, _ error) { trace.endPhase(PhaseConnect) },
+		TLSHandshakeStart: func() { trace.startPhase(PhaseTLS) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { trace.endPhase(PhaseTLS) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			trace.handshakeDone(start)
+			trace.connectedTo(info.Conn.RemoteAddr().String())
+			// Reused connections don't handshake again, so take the
+			// certificate from the connection rather than the handshake
+			if conn, ok := info.Conn.(*tls.Conn); ok {
+				if certs := conn.ConnectionState().PeerCertificates; len(certs) > 0 {
+					trace.setCert(newCertInfo(certs[0]))
+				}
+			}
+		},
+	}
+}
+
+// requestPhase returns the phase a smart http request belongs to
+func requestPhase(req *http.Request) (Phase, bool) {
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/info/refs"):
+		return PhaseRefDiscovery, true
+	case req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "-pack"):
+		return PhasePackTransfer, true
+	}
+	return "", false
+}
+
+// phaseBody calls done once the response body has been read to the end or
+// closed
+type phaseBody struct {
+	io.ReadCloser
+	done func()
+	once sync.Once
+}
+
+func (b *phaseBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.once.Do(b.done)
+	}
+	return n, err
+}
+
+func (b *phaseBody) Close() error {
+	b.once.Do(b.done)
+	return b.ReadCloser.Close()
+}
+
+// hostOf strips the port from addr
+func hostOf(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// withTransport attaches the transport to use for http requests to ctx
+func withTransport(ctx context.Context, rt http.RoundTripper) context.Context {
+	return context.WithValue(ctx, transportKey{}, rt)
+}
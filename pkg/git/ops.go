@@ -0,0 +1,188 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// Operation is a git operation a Client can run against a remote
+type Operation string
+
+const (
+	// OpLsRemote lists the remote's references
+	OpLsRemote Operation = "ls-remote"
+	// OpClone is a shallow, single-branch clone
+	OpClone Operation = "clone"
+	// OpFullClone is a single-branch clone with full history
+	OpFullClone Operation = "full-clone"
+	// OpFetch fetches into a repository kept from earlier fetches, cloning
+	// it the first time
+	OpFetch Operation = "fetch"
+	// OpPush force-pushes a new commit to the client's PushRef
+	OpPush Operation = "push"
+)
+
+// Operations lists every operation a Client can run
+var Operations = []Operation{OpLsRemote, OpClone, OpFullClone, OpFetch, OpPush}
+
+// DefaultPushRef is the branch OpPush writes to unless configured otherwise
+const DefaultPushRef = "refs/heads/gitter/probe"
+
+// fetchedRepo is an in-memory repository reused by OpFetch
+type fetchedRepo struct {
+	mu   sync.Mutex
+	repo *gogit.Repository
+}
+
+// run performs op once the remote options are known
+func (c *Client) run(ctx context.Context, op Operation, repo string, remote remoteOptions) error {
+	switch op {
+	case OpLsRemote:
+		r := gogit.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: "origin", URLs: []string{repo}})
+		_, err := r.ListContext(ctx, &gogit.ListOptions{Auth: remote.auth, ProxyOptions: remote.proxy, CABundle: remote.caBundle, InsecureSkipTLS: remote.insecure})
+		return err
+	case OpClone:
+		return clone(ctx, memory.NewStorage(), repo, 1, remote)
+	case OpFullClone:
+		return clone(ctx, memory.NewStorage(), repo, 0, remote)
+	case OpFetch:
+		return c.fetch(ctx, repo, remote)
+	case OpPush:
+		return c.push(ctx, repo, remote)
+	default:
+		return fmt.Errorf("unknown operation %q", op)
+	}
+}
+
+// clone clones repo into st without a worktree, limited to depth commits
+// when depth is positive
+func clone(ctx context.Context, st storage.Storer, repo string, depth int, remote remoteOptions) error {
+	_, err := gogit.CloneContext(ctx, st, nil, &gogit.CloneOptions{
+		URL:             repo,
+		Auth:            remote.auth,
+		ProxyOptions:    remote.proxy,
+		CABundle:        remote.caBundle,
+		InsecureSkipTLS: remote.insecure,
+		SingleBranch:    true,
+		NoCheckout:      true,
+		Depth:           depth,
+	})
+	return err
+}
+
+// fetch updates the repository kept for repo, cloning it on first use
+func (c *Client) fetch(ctx context.Context, repo string, remote remoteOptions) error {
+	c.mu.Lock()
+	f, ok := c.fetched[repo]
+	if !ok {
+		f = &fetchedRepo{}
+		c.fetched[repo] = f
+	}
+	c.mu.Unlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.repo == nil {
+		st := memory.NewStorage()
+		if err := clone(ctx, st, repo, 0, remote); err != nil {
+			return err
+		}
+		r, err := gogit.Open(st, nil)
+		if err != nil {
+			return err
+		}
+		f.repo = r
+		return nil
+	}
+
+	err := f.repo.FetchContext(ctx, &gogit.FetchOptions{Auth: remote.auth, ProxyOptions: remote.proxy, CABundle: remote.caBundle, InsecureSkipTLS: remote.insecure})
+	if errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		return nil
+	}
+	return err
+}
+
+// push force-pushes a new parentless commit to the client's PushRef. The
+// commit only contains a timestamp so every push transfers a new object.
+func (c *Client) push(ctx context.Context, repo string, remote remoteOptions) error {
+	st := memory.NewStorage()
+	r, err := gogit.Init(st, nil)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	content := fmt.Sprintf("gitter push probe at %s\n", now.Format(time.RFC3339Nano))
+	blob := st.NewEncodedObject()
+	blob.SetType(plumbing.BlobObject)
+	w, err := blob.Writer()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(content)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	blobHash, err := st.SetEncodedObject(blob)
+	if err != nil {
+		return err
+	}
+
+	treeHash, err := storeObject(st, &object.Tree{Entries: []object.TreeEntry{
+		{Name: "gitter-probe.txt", Mode: filemode.Regular, Hash: blobHash},
+	}})
+	if err != nil {
+		return err
+	}
+	sig := object.Signature{Name: "gitter", Email: "gitter@localhost", When: now}
+	commitHash, err := storeObject(st, &object.Commit{
+		Author:    sig,
+		Committer: sig,
+		Message:   "gitter push probe\n",
+		TreeHash:  treeHash,
+	})
+	if err != nil {
+		return err
+	}
+
+	local := plumbing.ReferenceName("refs/heads/gitter-probe")
+	if err := st.SetReference(plumbing.NewHashReference(local, commitHash)); err != nil {
+		return err
+	}
+	if _, err := r.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{repo}}); err != nil {
+		return err
+	}
+	return r.PushContext(ctx, &gogit.PushOptions{
+		RemoteName:      "origin",
+		RefSpecs:        []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", local, c.opts.PushRef))},
+		Auth:            remote.auth,
+		ProxyOptions:    remote.proxy,
+		CABundle:        remote.caBundle,
+		InsecureSkipTLS: remote.insecure,
+		Force:           true,
+	})
+}
+
+// storeObject encodes o into st and returns its hash
+func storeObject(st storage.Storer, o interface {
+	Encode(plumbing.EncodedObject) error
+}) (plumbing.Hash, error) {
+	obj := st.NewEncodedObject()
+	if err := o.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return st.SetEncodedObject(obj)
+}
@@ -0,0 +1,122 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// IPFamily restricts the address family clones connect over
+type IPFamily string
+
+const (
+	// IPAny lets the system choose, as Go normally does
+	IPAny IPFamily = ""
+	// IPv4 only connects over IPv4
+	IPv4 IPFamily = "4"
+	// IPv6 only connects over IPv6
+	IPv6 IPFamily = "6"
+)
+
+// network returns base ("tcp" or "ip") restricted to the family
+func (f IPFamily) network(base string) string {
+	return base + string(f)
+}
+
+func (f IPFamily) String() string {
+	if f == IPAny {
+		return "any"
+	}
+	return "IPv" + string(f)
+}
+
+// ParseResolve parses a curl-style "host:port:ip" override into the
+// "host:port" address it applies to and the IP to connect to instead
+func ParseResolve(s string) (string, string, error) {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) != 3 || parts[0] == "" {
+		return "", "", fmt.Errorf("resolve must be in the form host:port:ip, got %q", s)
+	}
+	host, port, ip := parts[0], parts[1], strings.Trim(parts[2], "[]")
+	if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+		return "", "", fmt.Errorf("invalid port in resolve %q", s)
+	}
+	if net.ParseIP(ip) == nil {
+		return "", "", fmt.Errorf("invalid IP address in resolve %q", s)
+	}
+	return net.JoinHostPort(host, port), ip, nil
+}
+
+// endpointAddr returns the host:port a clone of ep connects to
+func endpointAddr(ep *transport.Endpoint) string {
+	port := ep.Port
+	if port == 0 {
+		switch ep.Protocol {
+		case "http":
+			port = 80
+		case "https":
+			port = 443
+		case "ssh":
+			port = 22
+		}
+	}
+	return net.JoinHostPort(ep.Host, strconv.Itoa(port))
+}
+
+type pinsKey struct{}
+
+// withPins attaches a map of host:port addresses to the IPs that should be
+// dialled for them
+func withPins(ctx context.Context, pins map[string]string) context.Context {
+	return context.WithValue(ctx, pinsKey{}, pins)
+}
+
+// pinnedAddr rewrites addr to the IP pinned for it in ctx, if any
+func pinnedAddr(ctx context.Context, addr string) string {
+	pins, _ := ctx.Value(pinsKey{}).(map[string]string)
+	ip, ok := pins[addr]
+	if !ok {
+		return addr
+	}
+	_, port, _ := net.SplitHostPort(addr)
+	return net.JoinHostPort(ip, port)
+}
+
+// pins works out which addresses a clone of ep should connect to, applying
+// the static overrides and picking the next backend in round-robin mode
+func (c *Client) pins(ctx context.Context, ep *transport.Endpoint) (map[string]string, error) {
+	if len(c.opts.Resolve) == 0 && !c.opts.RoundRobin {
+		return nil, nil
+	}
+	pins := make(map[string]string, len(c.opts.Resolve)+1)
+	for addr, ip := range c.opts.Resolve {
+		pins[addr] = ip
+	}
+
+	addr := endpointAddr(ep)
+	if _, ok := pins[addr]; ok || !c.opts.RoundRobin {
+		return pins, nil
+	}
+
+	// Resolve on every clone so DNS changes are picked up mid-run
+	ips, err := net.DefaultResolver.LookupIP(ctx, c.opts.IPFamily.network("ip"), ep.Host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no %s addresses found for %s", c.opts.IPFamily, ep.Host)
+	}
+	backends := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		backends = append(backends, ip.String())
+	}
+	slices.Sort(backends)
+	backends = slices.Compact(backends)
+	pins[addr] = backends[int(c.next.Add(1)-1)%len(backends)]
+	return pins, nil
+}
@@ -0,0 +1,149 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyPolicy controls how unknown or changed SSH host keys are handled
+type HostKeyPolicy string
+
+const (
+	// HostKeyStrict only accepts hosts already present in known_hosts
+	HostKeyStrict HostKeyPolicy = "strict"
+	// HostKeyAcceptNew adds unknown hosts to known_hosts but rejects changed keys
+	HostKeyAcceptNew HostKeyPolicy = "accept-new"
+	// HostKeyInsecure accepts any host key
+	HostKeyInsecure HostKeyPolicy = "insecure"
+)
+
+// HostKeyPolicies lists the supported host key policies
+var HostKeyPolicies = []HostKeyPolicy{HostKeyStrict, HostKeyAcceptNew, HostKeyInsecure}
+
+// probeKeyType is the key type go-git (via skeema/knownhosts) passes to the
+// host key callback before dialling, to find which algorithms are known for
+// the host. Probes must not be treated as a real handshake.
+const probeKeyType = "fake-public-key"
+
+// SSHOptions configures authentication and host key checking for SSH URLs
+type SSHOptions struct {
+	// KeyFile is a private key to authenticate with. If empty the SSH agent
+	// from SSH_AUTH_SOCK is used.
+	KeyFile string
+	// KeyPassphrase decrypts KeyFile if it is encrypted
+	KeyPassphrase string
+	// KnownHosts is the known_hosts file to check against, defaulting to
+	// ~/.ssh/known_hosts
+	KnownHosts string
+	// HostKeyPolicy defaults to HostKeyStrict
+	HostKeyPolicy HostKeyPolicy
+}
+
+// sshAuth builds the auth method for an SSH clone, recording the end of the
+// handshake into trace when the host key is checked
+func sshAuth(user string, opts SSHOptions, trace *Trace, start time.Time) (gitssh.AuthMethod, error) {
+	if user == "" {
+		user = "git"
+	}
+
+	check, err := hostKeyCallback(opts)
+	if err != nil {
+		return nil, err
+	}
+	callback := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if key.Type() != probeKeyType {
+			trace.handshakeDone(start)
+			trace.connectedTo(remote.String())
+		}
+		return check(hostname, remote, key)
+	}
+
+	if opts.KeyFile != "" {
+		auth, err := gitssh.NewPublicKeysFromFile(user, opts.KeyFile, opts.KeyPassphrase)
+		if err != nil {
+			return nil, fmt.Errorf("loading ssh key %s: %w", opts.KeyFile, err)
+		}
+		auth.HostKeyCallback = callback
+		return auth, nil
+	}
+
+	auth, err := gitssh.NewSSHAgentAuth(user)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to ssh agent: %w", err)
+	}
+	auth.HostKeyCallback = callback
+	return auth, nil
+}
+
+// hostKeyCallback returns a callback implementing the configured policy
+func hostKeyCallback(opts SSHOptions) (ssh.HostKeyCallback, error) {
+	policy := opts.HostKeyPolicy
+	if policy == "" {
+		policy = HostKeyStrict
+	}
+	if policy == HostKeyInsecure {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	path := opts.KnownHosts
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(home, ".ssh", "known_hosts")
+	}
+
+	switch policy {
+	case HostKeyStrict:
+		return knownhosts.New(path)
+	case HostKeyAcceptNew:
+		return acceptNewCallback(path), nil
+	default:
+		return nil, fmt.Errorf("unknown host key policy %q", policy)
+	}
+}
+
+// acceptNewCallback checks hosts against known_hosts, adding any host that
+// isn't listed yet. The file is re-read on each call so hosts added by one
+// clone are known to the next.
+func acceptNewCallback(path string) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+				return err
+			}
+			if err := os.WriteFile(path, nil, 0o600); err != nil {
+				return err
+			}
+		}
+
+		check, err := knownhosts.New(path)
+		if err != nil {
+			return err
+		}
+		err = check(hostname, remote, key)
+
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) || len(keyErr.Want) > 0 || key.Type() == probeKeyType {
+			// Known and valid, known with a different key, or just a probe
+			return err
+		}
+
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		_, err = fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+		return err
+	}
+}
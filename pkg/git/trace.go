@@ -0,0 +1,175 @@
+package git
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Phase is a step of an operation that is timed on its own
+type Phase string
+
+const (
+	// PhaseDNS is looking up the server's addresses
+	PhaseDNS Phase = "dns"
+	// PhaseConnect is opening the TCP connection
+	PhaseConnect Phase = "connect"
+	// PhaseTLS is the TLS handshake
+	PhaseTLS Phase = "tls"
+	// PhaseRefDiscovery is fetching the references the server advertises
+	PhaseRefDiscovery Phase = "ref-discovery"
+	// PhasePackTransfer is sending or receiving the pack
+	PhasePackTransfer Phase = "pack-transfer"
+)
+
+// Span is a timed phase
+type Span struct {
+	Phase    Phase
+	Start    time.Time
+	Duration time.Duration
+}
+
+// Trace collects timings for a single Clone. Attach one to the context with
+// WithTrace and read it with Snapshot once Clone returns, as an operation cut
+// short by its context may still be recording into it.
+type Trace struct {
+	mu sync.Mutex
+	// Handshake is the time taken to connect and complete the transport
+	// handshake (TCP connect plus the TLS or SSH handshake)
+	Handshake time.Duration
+	// Transfer is the time after the handshake, covering reference discovery
+	// and the pack transfer
+	Transfer time.Duration
+	// Cert is the certificate presented by an https server
+	Cert *CertInfo
+	// Backend is the IP address of the server last connected to. It is left
+	// empty when connecting through a proxy, unless the address was pinned.
+	Backend string
+	// Family is the address family the clone was restricted to, if any
+	Family IPFamily
+	// Spans are the phases of an http or https clone in the order they
+	// finished, keeping the first of each. Phases that were skipped, such as
+	// DNS for a pinned address, are missing.
+	Spans []Span
+
+	open map[Phase]time.Time
+	// proxied is set when connections go to a proxy rather than the server
+	proxied bool
+}
+
+type traceKey struct{}
+
+// WithTrace returns a context that records Clone timings into t
+func WithTrace(ctx context.Context, t *Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+// traceFrom returns the Trace attached to ctx, or a throwaway one
+func traceFrom(ctx context.Context) *Trace {
+	if t, ok := ctx.Value(traceKey{}).(*Trace); ok && t != nil {
+		return t
+	}
+	return &Trace{}
+}
+
+// Snapshot returns a copy of the timings recorded so far
+func (t *Trace) Snapshot() Trace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return Trace{
+		Handshake: t.Handshake,
+		Transfer:  t.Transfer,
+		Cert:      t.Cert,
+		Backend:   t.Backend,
+		Family:    t.Family,
+		Spans:     slices.Clone(t.Spans),
+	}
+}
+
+// setCert records the server certificate, keeping the first one seen
+func (t *Trace) setCert(c *CertInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.Cert == nil {
+		t.Cert = c
+	}
+}
+
+// setBackend records the server address being connected to
+func (t *Trace) setBackend(ip string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Backend = ip
+}
+
+// setProxied records that connections go through a proxy, so their address
+// isn't the server's
+func (t *Trace) setProxied() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.proxied = true
+}
+
+// connectedTo records the address of a connection as the backend, unless it
+// is a proxy's
+func (t *Trace) connectedTo(addr string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.proxied {
+		t.Backend = hostOf(addr)
+	}
+}
+
+// setFamily records the address family the clone is restricted to
+func (t *Trace) setFamily(f IPFamily) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Family = f
+}
+
+// handshakeDone records the end of the handshake, keeping the first one seen
+func (t *Trace) handshakeDone(start time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.Handshake == 0 {
+		t.Handshake = time.Since(start)
+	}
+}
+
+// finish records the transfer time once the clone has completed
+func (t *Trace) finish(start time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Transfer = time.Since(start) - t.Handshake
+}
+
+// startPhase marks the start of p, unless it has already been timed
+func (t *Trace) startPhase(p Phase) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.open[p]; ok {
+		return
+	}
+	for _, s := range t.Spans {
+		if s.Phase == p {
+			return
+		}
+	}
+	if t.open == nil {
+		t.open = make(map[Phase]time.Time)
+	}
+	t.open[p] = time.Now()
+}
+
+// endPhase records a span for p if it was started with startPhase
+func (t *Trace) endPhase(p Phase) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	start, ok := t.open[p]
+	if !ok {
+		return
+	}
+	delete(t.open, p)
+	t.Spans = append(t.Spans, Span{Phase: p, Start: start, Duration: time.Since(start)})
+}
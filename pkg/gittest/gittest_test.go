@@ -0,0 +1,115 @@
+package gittest_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	"github.com/kloudyuk/gitter/pkg/gittest/gittesttb"
+)
+
+func TestServerClone(t *testing.T) {
+	url := gittesttb.NewTestServer(t, 5)
+
+	tests := []struct {
+		name        string
+		depth       int
+		wantCommits int
+	}{
+		{"full clone", 0, 5},
+		{"shallow clone", 1, 1},
+		{"depth 3", 3, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			repo, err := gogit.CloneContext(ctx, memory.NewStorage(), nil, &gogit.CloneOptions{
+				URL:        url,
+				Depth:      tt.depth,
+				NoCheckout: true,
+			})
+			if err != nil {
+				t.Fatalf("Clone failed: %v", err)
+			}
+
+			head, err := repo.Head()
+			if err != nil {
+				t.Fatalf("Failed to resolve HEAD: %v", err)
+			}
+			commits, err := repo.Log(&gogit.LogOptions{From: head.Hash()})
+			if err != nil {
+				t.Fatalf("Failed to read log: %v", err)
+			}
+			count := 0
+			_ = commits.ForEach(func(*object.Commit) error {
+				count++
+				return nil
+			})
+			if count != tt.wantCommits {
+				t.Errorf("Expected %d commits, got %d", tt.wantCommits, count)
+			}
+		})
+	}
+}
+
+func TestServerUnknownRepo(t *testing.T) {
+	url := gittesttb.NewTestServer(t, 1)
+
+	_, err := gogit.CloneContext(context.Background(), memory.NewStorage(), nil, &gogit.CloneOptions{
+		URL: url + "-missing",
+	})
+	if err == nil {
+		t.Error("Expected error cloning unknown repository")
+	}
+}
+
+func TestServerPush(t *testing.T) {
+	url := gittesttb.NewTestServer(t, 2)
+
+	dir := t.TempDir()
+	repo, err := gogit.PlainClone(dir, false, &gogit.CloneOptions{URL: url})
+	if err != nil {
+		t.Fatalf("Clone failed: %v", err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "pushed.txt"), []byte("pushed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.Add("pushed.txt"); err != nil {
+		t.Fatal(err)
+	}
+	sig := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	hash, err := wt.Commit("Push a commit", &gogit.CommitOptions{Author: sig})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Push(&gogit.PushOptions{}); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	// A fresh clone should see the pushed commit
+	fresh, err := gogit.Clone(memory.NewStorage(), nil, &gogit.CloneOptions{URL: url, NoCheckout: true})
+	if err != nil {
+		t.Fatalf("Clone after push failed: %v", err)
+	}
+	head, err := fresh.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Hash() != hash {
+		t.Errorf("Expected HEAD %s after push, got %s", hash, head.Hash())
+	}
+}
@@ -0,0 +1,82 @@
+// Package gittesttb starts gittest servers for the lifetime of a test. It is
+// kept apart from gittest so the testing package isn't linked into gitter.
+package gittesttb
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/kloudyuk/gitter/pkg/gittest"
+)
+
+// NewTestServer starts a gittest.Server on a local port serving a fixture
+// repository with the given number of commits and returns the repository's
+// clone URL. The server is shut down when the test ends.
+func NewTestServer(tb testing.TB, commits int) string {
+	tb.Helper()
+
+	ts := httptest.NewServer(newFixtureServer(tb, commits))
+	tb.Cleanup(ts.Close)
+
+	return ts.URL + "/" + gittest.FixtureName
+}
+
+// NewTestTLSServer is like NewTestServer but serves https with a self-signed
+// certificate, which is returned. If clientCAs is set, clients must present a
+// certificate signed by one of them.
+func NewTestTLSServer(tb testing.TB, commits int, clientCAs *x509.CertPool) (string, *x509.Certificate) {
+	tb.Helper()
+
+	ts := httptest.NewUnstartedServer(newFixtureServer(tb, commits))
+	// Rejected handshakes are expected in tests, don't log them
+	ts.Config.ErrorLog = log.New(io.Discard, "", 0)
+	if clientCAs != nil {
+		ts.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	}
+	ts.StartTLS()
+	tb.Cleanup(ts.Close)
+
+	return ts.URL + "/" + gittest.FixtureName, ts.Certificate()
+}
+
+// NewTestSSHServer starts a gittest.SSHServer on a local port serving a
+// fixture repository with the given number of commits. It returns the ssh://
+// clone URL and the server's host key. The server is shut down when the test
+// ends.
+func NewTestSSHServer(tb testing.TB, commits int) (string, ssh.PublicKey) {
+	tb.Helper()
+
+	srv, err := gittest.NewSSHServer(newFixtureServer(tb, commits))
+	if err != nil {
+		tb.Fatalf("Failed to create ssh server: %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatalf("Failed to listen: %v", err)
+	}
+	tb.Cleanup(func() { _ = ln.Close() })
+	go func() { _ = srv.Serve(ln) }()
+
+	return fmt.Sprintf("ssh://git@%s/%s", ln.Addr(), gittest.FixtureName), srv.HostKey
+}
+
+func newFixtureServer(tb testing.TB, commits int) *gittest.Server {
+	tb.Helper()
+
+	repo, err := gittest.CreateRepo(tb.TempDir(), commits)
+	if err != nil {
+		tb.Fatalf("Failed to create fixture repository: %v", err)
+	}
+
+	srv := gittest.NewServer()
+	srv.AddRepo(gittest.FixtureName, repo.Storer)
+	return srv
+}
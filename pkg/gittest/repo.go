@@ -0,0 +1,44 @@
+package gittest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// FixtureName is the name the default fixture repository is served under
+const FixtureName = "fixture.git"
+
+// CreateRepo initialises a repository in dir with the given number of commits,
+// each adding a file, so that shallow and full clones transfer different data
+func CreateRepo(dir string, commits int) (*gogit.Repository, error) {
+	repo, err := gogit.PlainInit(dir, false)
+	if err != nil {
+		return nil, err
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+
+	sig := &object.Signature{Name: "gitter", Email: "gitter@example.com", When: time.Unix(1700000000, 0)}
+	for i := 1; i <= commits; i++ {
+		name := fmt.Sprintf("file-%03d.txt", i)
+		content := fmt.Sprintf("fixture file %d\n", i)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			return nil, err
+		}
+		if _, err := wt.Add(name); err != nil {
+			return nil, err
+		}
+		sig.When = sig.When.Add(time.Minute)
+		if _, err := wt.Commit(fmt.Sprintf("Add %s", name), &gogit.CommitOptions{Author: sig, Committer: sig}); err != nil {
+			return nil, err
+		}
+	}
+	return repo, nil
+}
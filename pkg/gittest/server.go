@@ -0,0 +1,305 @@
+// Package gittest provides an in-process git smart-HTTP server backed by
+// repositories created with go-git, so real clones can be exercised offline.
+package gittest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/plumbing/format/pktline"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+	"github.com/go-git/go-git/v5/storage"
+)
+
+// Server is an http.Handler implementing the git smart-HTTP protocol for a set
+// of named repositories. Repositories are served at /<name>/info/refs etc.
+type Server struct {
+	mu    sync.RWMutex
+	repos server.MapLoader
+}
+
+// NewServer creates a Server with no repositories
+func NewServer() *Server {
+	return &Server{repos: server.MapLoader{}}
+}
+
+// AddRepo serves st under name, e.g. "fixture.git"
+func (s *Server) AddRepo(name string, st storage.Storer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.repos[repoKey(name)] = st
+}
+
+func repoKey(name string) string {
+	return "file:///" + strings.Trim(name, "/")
+}
+
+// repo returns the repository served under name
+func (s *Server) repo(name string) (storer.Storer, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	st, found := s.repos[repoKey(name)]
+	return st, found
+}
+
+// load finds the repository a request path refers to and the remaining path
+func (s *Server) load(path string) (storer.Storer, *transport.Endpoint, string, bool) {
+	for _, suffix := range []string{"/info/refs", "/" + transport.UploadPackServiceName, "/" + transport.ReceivePackServiceName} {
+		if name, ok := strings.CutSuffix(path, suffix); ok {
+			st, found := s.repo(name)
+			if !found {
+				return nil, nil, "", false
+			}
+			ep, err := transport.NewEndpoint(repoKey(name))
+			if err != nil {
+				return nil, nil, "", false
+			}
+			return st, ep, suffix, true
+		}
+	}
+	return nil, nil, "", false
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	st, ep, route, ok := s.load(r.URL.Path)
+	if !ok {
+		http.Error(w, "repository not found", http.StatusNotFound)
+		return
+	}
+
+	var err error
+	switch {
+	case route == "/info/refs" && r.Method == http.MethodGet:
+		err = s.infoRefs(w, r, st, ep)
+	case route == "/"+transport.UploadPackServiceName && r.Method == http.MethodPost:
+		w.Header().Set("Content-Type", "application/x-git-upload-pack-result")
+		err = uploadPack(w, r.Body, st)
+	case route == "/"+transport.ReceivePackServiceName && r.Method == http.MethodPost:
+		err = s.receivePack(w, r, ep)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// infoRefs serves the reference advertisement for either service
+func (s *Server) infoRefs(w http.ResponseWriter, r *http.Request, st storer.Storer, ep *transport.Endpoint) error {
+	service := r.URL.Query().Get("service")
+
+	var ar *packp.AdvRefs
+	var err error
+	switch service {
+	case transport.UploadPackServiceName:
+		ar, err = advertiseUploadPack(r.Context(), st)
+	case transport.ReceivePackServiceName:
+		var sess transport.ReceivePackSession
+		if sess, err = server.NewServer(s.loader()).NewReceivePackSession(ep, nil); err == nil {
+			ar, err = sess.AdvertisedReferencesContext(r.Context())
+		}
+	default:
+		http.Error(w, "only smart HTTP is supported", http.StatusForbidden)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	ar.Prefix = [][]byte{[]byte("# service=" + service), pktline.Flush}
+	w.Header().Set("Content-Type", fmt.Sprintf("application/x-%s-advertisement", service))
+	w.Header().Set("Cache-Control", "no-cache")
+	return ar.Encode(w)
+}
+
+// loader returns a snapshot of the repositories for go-git's server
+func (s *Server) loader() server.MapLoader {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	repos := make(server.MapLoader, len(s.repos))
+	for k, v := range s.repos {
+		repos[k] = v
+	}
+	return repos
+}
+
+// advertiseUploadPack lists the references using go-git's server and adds the
+// shallow capability, which go-git's server does not support but we do
+func advertiseUploadPack(ctx context.Context, st storer.Storer) (*packp.AdvRefs, error) {
+	ep, _ := transport.NewEndpoint("file:///repo")
+	sess, err := server.NewServer(server.MapLoader{ep.String(): st}).NewUploadPackSession(ep, nil)
+	if err != nil {
+		return nil, err
+	}
+	ar, err := sess.AdvertisedReferencesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := ar.Capabilities.Set(capability.Shallow); err != nil {
+		return nil, err
+	}
+	return ar, nil
+}
+
+// uploadPack negotiates and sends a packfile, honouring "deepen N" requests.
+// Haves are read but not used to trim the pack: the fixture always sends
+// everything reachable from the wants, which is all a clone needs.
+func uploadPack(w io.Writer, r io.Reader, st storer.Storer) error {
+	body := bufio.NewReader(r)
+	req := packp.NewUploadPackRequest()
+	if err := req.UploadRequest.Decode(body); err != nil {
+		return err
+	}
+	done := false
+	scanner := pktline.NewScanner(body)
+	for scanner.Scan() {
+		if bytes.Equal(bytes.TrimSpace(scanner.Bytes()), []byte("done")) {
+			done = true
+			break
+		}
+	}
+
+	depth := 0
+	if d, ok := req.Depth.(packp.DepthCommits); ok {
+		depth = int(d)
+	}
+	objs, shallows, err := objectsToSend(st, req.Wants, depth)
+	if err != nil {
+		return err
+	}
+
+	// CLI git negotiates over several stateless requests and only expects the
+	// pack once it has sent "done"; until then it just wants the shallow list
+	if !done {
+		if depth > 0 {
+			update := packp.ShallowUpdate{Shallows: shallows}
+			return update.Encode(w)
+		}
+		return pktline.NewEncoder(w).EncodeString("NAK\n")
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := packfile.NewEncoder(pw, st, false).Encode(objs, 10)
+		pw.CloseWithError(err)
+	}()
+
+	resp := packp.NewUploadPackResponseWithPackfile(req, pr)
+	resp.ShallowUpdate.Shallows = shallows
+	return resp.Encode(w)
+}
+
+// objectsToSend walks history from wants, stopping after depth commits when
+// depth is positive. It returns every object reachable from the included
+// commits and the commits at the shallow boundary.
+func objectsToSend(st storer.Storer, wants []plumbing.Hash, depth int) ([]plumbing.Hash, []plumbing.Hash, error) {
+	seen := map[plumbing.Hash]bool{}
+	var objs, shallows []plumbing.Hash
+
+	add := func(h plumbing.Hash) bool {
+		if seen[h] {
+			return false
+		}
+		seen[h] = true
+		objs = append(objs, h)
+		return true
+	}
+
+	type entry struct {
+		hash  plumbing.Hash
+		depth int
+	}
+	queue := make([]entry, 0, len(wants))
+	for _, h := range wants {
+		queue = append(queue, entry{h, 1})
+	}
+	for len(queue) > 0 {
+		e := queue[0]
+		queue = queue[1:]
+		if !add(e.hash) {
+			continue
+		}
+		commit, err := object.GetCommit(st, e.hash)
+		if err != nil {
+			return nil, nil, err
+		}
+		tree, err := commit.Tree()
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := addTree(tree, add); err != nil {
+			return nil, nil, err
+		}
+		if depth > 0 && e.depth >= depth {
+			if commit.NumParents() > 0 {
+				shallows = append(shallows, e.hash)
+			}
+			continue
+		}
+		for _, p := range commit.ParentHashes {
+			queue = append(queue, entry{p, e.depth + 1})
+		}
+	}
+	return objs, shallows, nil
+}
+
+func addTree(tree *object.Tree, add func(plumbing.Hash) bool) error {
+	if !add(tree.Hash) {
+		return nil
+	}
+	for _, entry := range tree.Entries {
+		if entry.Mode.IsFile() {
+			add(entry.Hash)
+			continue
+		}
+		if entry.Mode != filemode.Dir {
+			// Submodules point at commits in other repositories
+			continue
+		}
+		sub, err := tree.Tree(entry.Name)
+		if err != nil {
+			return err
+		}
+		if err := addTree(sub, add); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// receivePack accepts a push using go-git's server implementation
+func (s *Server) receivePack(w http.ResponseWriter, r *http.Request, ep *transport.Endpoint) error {
+	sess, err := server.NewServer(s.loader()).NewReceivePackSession(ep, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := sess.AdvertisedReferencesContext(r.Context()); err != nil {
+		return err
+	}
+
+	req := packp.NewReferenceUpdateRequest()
+	if err := req.Decode(r.Body); err != nil {
+		return err
+	}
+	status, err := sess.ReceivePack(r.Context(), req)
+	if status == nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/x-git-receive-pack-result")
+	return status.Encode(w)
+}
@@ -0,0 +1,118 @@
+package gittest
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHServer serves the upload-pack service of a Server's repositories over
+// SSH. Any client key is accepted.
+type SSHServer struct {
+	repos  *Server
+	config *ssh.ServerConfig
+	// HostKey is the server's public host key, for populating known_hosts
+	HostKey ssh.PublicKey
+}
+
+// NewSSHServer creates an SSHServer for the repositories in repos with a
+// freshly generated host key
+func NewSSHServer(repos *Server) (*SSHServer, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(ssh.ConnMetadata, ssh.PublicKey) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+	return &SSHServer{repos: repos, config: config, HostKey: signer.PublicKey()}, nil
+}
+
+// Serve accepts connections on ln until it is closed
+func (s *SSHServer) Serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+func (s *SSHServer) handleConn(conn net.Conn) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChan := range chans {
+		if newChan.ChannelType() != "session" {
+			_ = newChan.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		ch, chReqs, err := newChan.Accept()
+		if err != nil {
+			continue
+		}
+		go s.handleSession(ch, chReqs)
+	}
+}
+
+// handleSession runs a single "exec git-upload-pack '<repo>'" request
+func (s *SSHServer) handleSession(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	defer func() { _ = ch.Close() }()
+	for req := range reqs {
+		if req.Type != "exec" || len(req.Payload) < 4 {
+			_ = req.Reply(false, nil)
+			continue
+		}
+		command := string(req.Payload[4:])
+		_ = req.Reply(true, nil)
+
+		status := uint32(0)
+		if err := s.exec(ch, command); err != nil {
+			_, _ = fmt.Fprintf(ch.Stderr(), "%s\n", err)
+			status = 1
+		}
+		payload := make([]byte, 4)
+		binary.BigEndian.PutUint32(payload, status)
+		_, _ = ch.SendRequest("exit-status", false, payload)
+		return
+	}
+}
+
+func (s *SSHServer) exec(ch ssh.Channel, command string) error {
+	service, path, ok := strings.Cut(command, " ")
+	if !ok || service != transport.UploadPackServiceName {
+		return fmt.Errorf("unsupported command %q", command)
+	}
+	path = strings.Trim(path, "'\"")
+
+	st, found := s.repos.repo(path)
+	if !found {
+		return fmt.Errorf("repository not found")
+	}
+	ar, err := advertiseUploadPack(context.Background(), st)
+	if err != nil {
+		return err
+	}
+	if err := ar.Encode(ch); err != nil {
+		return err
+	}
+	return uploadPack(ch, ch, st)
+}
@@ -0,0 +1,439 @@
+// Package history records runs to disk so they can be listed, inspected and
+// compared long after they finished.
+//
+// Each run gets its own directory holding run.json, describing the run and
+// summarising its results, and results.ndjson, with a line per attempt.
+package history
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kloudyuk/gitter/pkg/git"
+	"github.com/kloudyuk/gitter/pkg/runner"
+)
+
+const (
+	// RunFile describes a run and summarises its results
+	RunFile = "run.json"
+	// ResultsFile holds a JSON record per attempt, one per line
+	ResultsFile = "results.ndjson"
+)
+
+// DefaultKeep is how many runs are kept unless told otherwise; older ones
+// are removed as new runs start
+const DefaultKeep = 100
+
+// DefaultDir returns the directory runs are kept in:
+// $XDG_DATA_HOME/gitter/runs, or ~/.local/share/gitter/runs if that isn't set
+func DefaultDir() (string, error) {
+	if data := os.Getenv("XDG_DATA_HOME"); data != "" {
+		return filepath.Join(data, "gitter", "runs"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "gitter", "runs"), nil
+}
+
+// Run describes a recorded run
+type Run struct {
+	ID        string    `json:"id"`
+	Target    string    `json:"target"`
+	Operation string    `json:"operation"`
+	Start     time.Time `json:"start"`
+	// End is zero while the run is in progress, or if it never finished
+	End time.Time `json:"end,omitzero"`
+	// Config holds the command line flags the run was started with
+	Config map[string]string `json:"config,omitempty"`
+	// Summary is filled in when the run finishes
+	Summary *Summary `json:"summary,omitempty"`
+	// Dir is the directory the run is stored in
+	Dir string `json:"-"`
+}
+
+// Result is the record kept for each attempt
+type Result struct {
+	ID        int           `json:"id"`
+	Start     time.Time     `json:"start"`
+	Duration  time.Duration `json:"duration_ns"`
+	Op        string        `json:"op,omitempty"`
+	Repo      string        `json:"repo,omitempty"`
+	Backend   string        `json:"backend,omitempty"`
+	Handshake time.Duration `json:"handshake_ns,omitempty"`
+	Transfer  time.Duration `json:"transfer_ns,omitempty"`
+	Retries   int           `json:"retries,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Class     string        `json:"class,omitempty"`
+}
+
+// NewResult creates the record for an attempt, hiding any credentials in
+// the repository URL
+func NewResult(a runner.Attempt) Result {
+	r := Result{
+		ID:        a.ID,
+		Start:     a.Start,
+		Duration:  a.Duration,
+		Op:        string(a.Op),
+		Repo:      git.RedactURL(a.Repo),
+		Backend:   a.Backend,
+		Handshake: a.Handshake,
+		Transfer:  a.Transfer,
+		Retries:   a.Retries,
+		Class:     string(a.Class),
+	}
+	if a.Err != nil {
+		r.Error = a.Err.Error()
+	}
+	return r
+}
+
+// Failed reports whether the attempt failed
+func (r Result) Failed() bool {
+	return r.Error != ""
+}
+
+// Summary totals a run's results
+type Summary struct {
+	Success int           `json:"success"`
+	Fail    int           `json:"fail"`
+	Elapsed time.Duration `json:"elapsed_ns"`
+	P50     time.Duration `json:"p50_ns"`
+	P90     time.Duration `json:"p90_ns"`
+	P99     time.Duration `json:"p99_ns"`
+	// Classes counts the failures in each error class
+	Classes map[string]int `json:"classes,omitempty"`
+}
+
+// Summarise totals results from a run that lasted elapsed
+func Summarise(results []Result, elapsed time.Duration) Summary {
+	var t tally
+	for _, r := range results {
+		t.add(r)
+	}
+	return t.summary(elapsed)
+}
+
+// tally accumulates results for a Summary. Only their durations are kept,
+// for the percentiles.
+type tally struct {
+	success   int
+	fail      int
+	classes   map[string]int
+	durations []time.Duration
+}
+
+func (t *tally) add(r Result) {
+	if r.Failed() {
+		t.fail++
+		if t.classes == nil {
+			t.classes = map[string]int{}
+		}
+		t.classes[r.Class]++
+	} else {
+		t.success++
+	}
+	t.durations = append(t.durations, r.Duration)
+}
+
+func (t *tally) summary(elapsed time.Duration) Summary {
+	durations := slices.Sorted(slices.Values(t.durations))
+	return Summary{
+		Success: t.success,
+		Fail:    t.fail,
+		Elapsed: elapsed,
+		P50:     runner.Percentile(durations, 50),
+		P90:     runner.Percentile(durations, 90),
+		P99:     runner.Percentile(durations, 99),
+		Classes: t.classes,
+	}
+}
+
+// Attempts returns the number of attempts
+func (s Summary) Attempts() int {
+	return s.Success + s.Fail
+}
+
+// SuccessRate returns the percentage of attempts that succeeded
+func (s Summary) SuccessRate() float64 {
+	if s.Attempts() == 0 {
+		return 0
+	}
+	return float64(s.Success) / float64(s.Attempts()) * 100
+}
+
+// Throughput returns the attempts completed per second
+func (s Summary) Throughput() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Attempts()) / s.Elapsed.Seconds()
+}
+
+// Recorder writes a run to disk as it happens. It is safe for concurrent use.
+type Recorder struct {
+	mu     sync.Mutex
+	run    Run
+	file   *os.File
+	out    *bufio.Writer
+	tally  tally
+	err    error
+	closed bool
+}
+
+// NewRecorder creates a directory for run under root and starts recording
+// to it. An ID and start time are chosen if run doesn't have them.
+func NewRecorder(root string, run Run) (*Recorder, error) {
+	if run.Start.IsZero() {
+		run.Start = time.Now()
+	}
+	if run.ID == "" {
+		run.ID = newID(run.Start)
+	}
+	run.Dir = filepath.Join(root, run.ID)
+	if err := os.MkdirAll(run.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating run directory: %w", err)
+	}
+	if err := writeRun(run); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(filepath.Join(run.Dir, ResultsFile))
+	if err != nil {
+		return nil, fmt.Errorf("creating results file: %w", err)
+	}
+	return &Recorder{run: run, file: f, out: bufio.NewWriter(f)}, nil
+}
+
+// newID returns a run ID that sorts by start time
+func newID(start time.Time) string {
+	b := make([]byte, 2)
+	_, _ = rand.Read(b)
+	return start.Format("20060102-150405") + "-" + hex.EncodeToString(b)
+}
+
+// Run returns the run being recorded
+func (r *Recorder) Run() Run {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.run
+}
+
+// Record appends an attempt to the results. Attempts arriving after Close
+// are ignored.
+func (r *Recorder) Record(a runner.Attempt) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	result := NewResult(a)
+	r.tally.add(result)
+	if r.err != nil {
+		return
+	}
+	b, err := json.Marshal(result)
+	if err == nil {
+		b = append(b, '\n')
+		_, err = r.out.Write(b)
+	}
+	r.err = err
+}
+
+// Close finishes the run, writing its summary, and returns any error hit
+// while recording
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return r.err
+	}
+	r.closed = true
+	r.run.End = time.Now()
+	summary := r.tally.summary(r.run.End.Sub(r.run.Start))
+	r.run.Summary = &summary
+	r.err = errors.Join(r.err, r.out.Flush(), r.file.Close(), writeRun(r.run))
+	return r.err
+}
+
+// writeRun saves run's description into its directory
+func writeRun(run Run) error {
+	b, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(run.Dir, RunFile), append(b, '\n'), 0o644); err != nil {
+		return fmt.Errorf("writing run: %w", err)
+	}
+	return nil
+}
+
+// List returns the runs recorded under root, oldest first
+func List(root string) ([]Run, error) {
+	entries, err := os.ReadDir(root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var runs []Run
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		run, err := readRun(filepath.Join(root, e.Name()))
+		if errors.Is(err, fs.ErrNotExist) {
+			// Not a run directory
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	slices.SortFunc(runs, func(a, b Run) int {
+		return a.Start.Compare(b.Start)
+	})
+	return runs, nil
+}
+
+// Prune removes all but the newest keep runs under root, returning the runs
+// removed. A keep of zero or less keeps every run.
+func Prune(root string, keep int) ([]Run, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+	runs, err := List(root)
+	if err != nil || len(runs) <= keep {
+		return nil, err
+	}
+	old := runs[:len(runs)-keep]
+	for i, run := range old {
+		if err := os.RemoveAll(run.Dir); err != nil {
+			return old[:i], err
+		}
+	}
+	return old, nil
+}
+
+// readRun reads the description of the run stored in dir
+func readRun(dir string) (Run, error) {
+	b, err := os.ReadFile(filepath.Join(dir, RunFile))
+	if err != nil {
+		return Run{}, err
+	}
+	var run Run
+	if err := json.Unmarshal(b, &run); err != nil {
+		return Run{}, fmt.Errorf("reading %s: %w", filepath.Join(dir, RunFile), err)
+	}
+	run.Dir = dir
+	return run, nil
+}
+
+// Find returns the run under root whose ID is id, or starts with id if that
+// picks out a single run. Like Load, a run that never finished is summarised
+// from its results.
+func Find(root, id string) (Run, error) {
+	runs, err := List(root)
+	if err != nil {
+		return Run{}, err
+	}
+	var matches []Run
+	for _, run := range runs {
+		if run.ID == id {
+			matches = []Run{run}
+			break
+		}
+		if strings.HasPrefix(run.ID, id) {
+			matches = append(matches, run)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Run{}, fmt.Errorf("no run %s in %s", id, root)
+	case 1:
+	default:
+		return Run{}, fmt.Errorf("run %s is ambiguous, it could be any of %d runs", id, len(matches))
+	}
+
+	return summarise(matches[0])
+}
+
+// Load returns the run stored in dir. A run that never finished is
+// summarised from its results.
+func Load(dir string) (Run, error) {
+	run, err := readRun(dir)
+	if err != nil {
+		return Run{}, err
+	}
+	return summarise(run)
+}
+
+// summarise fills in the summary of a run that never finished from its
+// results
+func summarise(run Run) (Run, error) {
+	if run.Summary != nil {
+		return run, nil
+	}
+	results, err := LoadResults(run)
+	if err != nil {
+		return Run{}, err
+	}
+	var elapsed time.Duration
+	if n := len(results); n > 0 {
+		last := results[n-1]
+		elapsed = last.Start.Add(last.Duration).Sub(run.Start)
+	}
+	summary := Summarise(results, elapsed)
+	run.Summary = &summary
+	return run, nil
+}
+
+// LoadResults reads every attempt recorded for run
+func LoadResults(run Run) ([]Result, error) {
+	f, err := os.Open(filepath.Join(run.Dir, ResultsFile))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return ReadResults(f)
+}
+
+// ReadResults reads results written one JSON record per line. A truncated
+// last line, left by a run that was killed, is ignored.
+func ReadResults(r io.Reader) ([]Result, error) {
+	var results []Result
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	var pending error
+	for scanner.Scan() {
+		line++
+		if pending != nil {
+			return nil, pending
+		}
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var result Result
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			pending = fmt.Errorf("line %d: %w", line, err)
+			continue
+		}
+		results = append(results, result)
+	}
+	return results, scanner.Err()
+}
@@ -0,0 +1,48 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Command runs a shell command for every event, describing the event in
+// GITTER_* environment variables
+type Command struct {
+	Command string
+}
+
+func (c *Command) Notify(ctx context.Context, e Event) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.Command)
+	cmd.Env = append(os.Environ(), env(e)...)
+	cmd.WaitDelay = time.Second
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// env describes e as environment variables
+func env(e Event) []string {
+	vars := []string{
+		"GITTER_EVENT=" + string(e.Kind),
+		"GITTER_TIME=" + e.Time.Format(time.RFC3339),
+		"GITTER_TARGET=" + e.Target,
+		"GITTER_MESSAGE=" + e.Message,
+		"GITTER_FAILURES=" + strconv.Itoa(e.Failures),
+		"GITTER_ERROR=" + e.Error,
+		"GITTER_CLASS=" + e.Class,
+		"GITTER_RULE=" + e.Rule,
+		"GITTER_LEVEL=" + e.Level,
+		"GITTER_DURATION=" + strconv.FormatFloat(e.Duration.Seconds(), 'f', 0, 64),
+	}
+	if !e.Since.IsZero() {
+		vars = append(vars, "GITTER_SINCE="+e.Since.Format(time.RFC3339))
+	}
+	return vars
+}
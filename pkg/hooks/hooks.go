@@ -0,0 +1,231 @@
+// Package hooks notifies external systems when a run changes state, such as
+// when the server goes down and when it recovers.
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/kloudyuk/gitter/pkg/git"
+	"github.com/kloudyuk/gitter/pkg/runner"
+)
+
+// DefaultOutageAfter is how many consecutive failures start an outage
+const DefaultOutageAfter = 3
+
+// notifyTimeout bounds how long a single notifier may take
+const notifyTimeout = 10 * time.Second
+
+// queueSize is how many events may wait for delivery before new ones are
+// dropped
+const queueSize = 64
+
+// EventKind identifies what happened
+type EventKind string
+
+const (
+	// OutageStart fires when consecutive failures reach the outage threshold
+	OutageStart EventKind = "outage_start"
+	// OutageEnd fires on the first success after an outage
+	OutageEnd EventKind = "outage_end"
+	// ThresholdBreach fires when an alert rule changes level
+	ThresholdBreach EventKind = "threshold_breach"
+)
+
+// Event describes a state change
+type Event struct {
+	Kind    EventKind `json:"event"`
+	Time    time.Time `json:"time"`
+	Target  string    `json:"target"`
+	Message string    `json:"message"`
+	// Since is when the outage or breach began
+	Since time.Time `json:"since,omitzero"`
+	// Duration is how long the outage lasted, for OutageEnd
+	Duration time.Duration `json:"-"`
+	// Failures is the number of consecutive failures seen
+	Failures int    `json:"failures,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Class    string `json:"class,omitempty"`
+	// Rule and Level describe the alert rule behind a ThresholdBreach
+	Rule  string `json:"rule,omitempty"`
+	Level string `json:"level,omitempty"`
+}
+
+// Notifier delivers events somewhere outside gitter
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// OutageDetector turns a stream of attempts into outage start and end events
+type OutageDetector struct {
+	after    int
+	failures int
+	since    time.Time
+	down     bool
+}
+
+// NewOutageDetector creates an OutageDetector that starts an outage after
+// the given number of consecutive failures
+func NewOutageDetector(after int) *OutageDetector {
+	return &OutageDetector{after: max(after, 1)}
+}
+
+// Observe records an attempt, returning an event if it started or ended an
+// outage
+func (d *OutageDetector) Observe(a runner.Attempt) (Event, bool) {
+	if a.Err == nil {
+		d.failures = 0
+		if !d.down {
+			return Event{}, false
+		}
+		d.down = false
+		end := a.Start.Add(a.Duration)
+		return Event{
+			Kind:     OutageEnd,
+			Time:     end,
+			Message:  fmt.Sprintf("recovered after %s", end.Sub(d.since).Round(time.Second)),
+			Since:    d.since,
+			Duration: end.Sub(d.since),
+		}, true
+	}
+
+	d.failures++
+	if d.failures == 1 {
+		d.since = a.Start
+	}
+	if d.down || d.failures < d.after {
+		return Event{}, false
+	}
+	d.down = true
+	return Event{
+		Kind:     OutageStart,
+		Time:     a.Start.Add(a.Duration),
+		Message:  fmt.Sprintf("%d consecutive failures: %v", d.failures, a.Err),
+		Since:    d.since,
+		Failures: d.failures,
+		Error:    a.Err.Error(),
+		Class:    string(a.Class),
+	}, true
+}
+
+// Dispatcher watches attempts for outages and sends events to every
+// notifier in order, without holding up the run
+type Dispatcher struct {
+	target    string
+	notifiers []Notifier
+	log       *slog.Logger
+	mu        sync.Mutex
+	outage    *OutageDetector
+	queue     chan Event
+	closed    bool
+	pending   sync.WaitGroup
+	// done is closed once deliver has returned
+	done chan struct{}
+}
+
+// NewDispatcher creates a Dispatcher for events about target. Delivery
+// failures are written to log if it isn't nil.
+func NewDispatcher(target string, outageAfter int, log *slog.Logger, notifiers ...Notifier) *Dispatcher {
+	d := &Dispatcher{
+		target:    target,
+		notifiers: notifiers,
+		log:       log,
+		outage:    NewOutageDetector(outageAfter),
+		queue:     make(chan Event, queueSize),
+		done:      make(chan struct{}),
+	}
+	go d.deliver()
+	return d
+}
+
+// Observe checks a completed attempt for the start or end of an outage
+func (d *Dispatcher) Observe(a runner.Attempt) {
+	d.mu.Lock()
+	e, ok := d.outage.Observe(a)
+	d.mu.Unlock()
+	if ok {
+		d.Fire(e)
+	}
+}
+
+// Fire queues e for every notifier. If too many events are waiting, or the
+// Dispatcher has been closed, it is dropped rather than holding up the
+// caller. Any credentials in the target are hidden, as it is sent to other
+// services.
+func (d *Dispatcher) Fire(e Event) {
+	if e.Target == "" {
+		e.Target = d.target
+	}
+	e.Target = git.RedactURL(e.Target)
+	if d.log != nil {
+		d.log.Warn("hook event", "event", string(e.Kind), "target", e.Target, "message", e.Message)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	reason := "closed"
+	if !d.closed {
+		d.pending.Add(1)
+		select {
+		case d.queue <- e:
+			return
+		default:
+			d.pending.Done()
+			reason = "queue full"
+		}
+	}
+	if d.log != nil {
+		d.log.Error("hook event dropped", "event", string(e.Kind), "reason", reason)
+	}
+}
+
+// deliver sends queued events to the notifiers one at a time
+func (d *Dispatcher) deliver() {
+	defer close(d.done)
+	for e := range d.queue {
+		for _, n := range d.notifiers {
+			ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+			if err := n.Notify(ctx, e); err != nil && d.log != nil {
+				d.log.Error("hook failed", "event", string(e.Kind), "error", err.Error())
+			}
+			cancel()
+		}
+		d.pending.Done()
+	}
+}
+
+// Wait blocks until every event fired so far has been delivered, returning
+// ctx's error if it is done first
+func (d *Dispatcher) Wait(ctx context.Context) error {
+	delivered := make(chan struct{})
+	go func() {
+		d.pending.Wait()
+		close(delivered)
+	}()
+	select {
+	case <-delivered:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting events and waits for those already queued to be
+// delivered, returning ctx's error if it is done first. Events fired after
+// Close are dropped. It is safe to call more than once.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
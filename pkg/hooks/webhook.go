@@ -0,0 +1,129 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Webhook payload formats
+const (
+	FormatJSON  = "json"
+	FormatSlack = "slack"
+	FormatTeams = "teams"
+)
+
+// Formats lists the supported webhook payload formats
+var Formats = []string{FormatJSON, FormatSlack, FormatTeams}
+
+// Webhook POSTs events to a URL as JSON
+type Webhook struct {
+	URL string
+	// Format is one of Formats, defaulting to FormatJSON
+	Format string
+	// Client defaults to http.DefaultClient
+	Client *http.Client
+}
+
+func (w *Webhook) Notify(ctx context.Context, e Event) error {
+	body, err := w.payload(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// payload renders e in the webhook's format
+func (w *Webhook) payload(e Event) ([]byte, error) {
+	switch w.Format {
+	case "", FormatJSON:
+		return json.Marshal(struct {
+			Event
+			DurationSeconds float64 `json:"duration_seconds,omitempty"`
+		}{e, e.Duration.Seconds()})
+	case FormatSlack:
+		return json.Marshal(map[string]string{"text": fmt.Sprintf("%s *%s*: %s", emoji(e), e.Target, e.Message)})
+	case FormatTeams:
+		return json.Marshal(map[string]any{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    title(e),
+			"themeColor": colour(e),
+			"title":      fmt.Sprintf("%s: %s", title(e), e.Target),
+			"text":       e.Message,
+			"sections": []map[string]any{{
+				"facts": facts(e),
+			}},
+		})
+	default:
+		return nil, fmt.Errorf("unknown webhook format %q", w.Format)
+	}
+}
+
+func title(e Event) string {
+	switch e.Kind {
+	case OutageStart:
+		return "Outage started"
+	case OutageEnd:
+		return "Outage ended"
+	default:
+		return fmt.Sprintf("Threshold %s", e.Level)
+	}
+}
+
+func emoji(e Event) string {
+	switch {
+	case e.Kind == OutageEnd || e.Level == "ok":
+		return ":white_check_mark:"
+	case e.Kind == OutageStart || e.Level == "crit":
+		return ":red_circle:"
+	default:
+		return ":warning:"
+	}
+}
+
+func colour(e Event) string {
+	switch {
+	case e.Kind == OutageEnd || e.Level == "ok":
+		return "2EB67D"
+	case e.Kind == OutageStart || e.Level == "crit":
+		return "E01E5A"
+	default:
+		return "ECB22E"
+	}
+}
+
+// facts lists the event's details as Teams name/value pairs
+func facts(e Event) []map[string]string {
+	f := []map[string]string{{"name": "Time", "value": e.Time.Format(time.RFC3339)}}
+	if !e.Since.IsZero() {
+		f = append(f, map[string]string{"name": "Since", "value": e.Since.Format(time.RFC3339)})
+	}
+	if e.Rule != "" {
+		f = append(f, map[string]string{"name": "Rule", "value": e.Rule})
+	}
+	if e.Error != "" {
+		f = append(f, map[string]string{"name": "Error", "value": e.Error})
+	}
+	return f
+}
@@ -0,0 +1,38 @@
+package jsontime
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    time.Duration
+		wantErr bool
+	}{
+		{`"250ms"`, 250 * time.Millisecond, false},
+		{`"1m30s"`, 90 * time.Second, false},
+		{`90`, 0, true},
+		{`"soon"`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.json, func(t *testing.T) {
+			var d Duration
+			err := json.Unmarshal([]byte(tt.json), &d)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if time.Duration(d) != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, time.Duration(d))
+			}
+		})
+	}
+
+	b, err := json.Marshal(Duration(90 * time.Second))
+	if err != nil || string(b) != `"1m30s"` {
+		t.Errorf("Expected \"1m30s\", got %s (%v)", b, err)
+	}
+}
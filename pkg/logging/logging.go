@@ -0,0 +1,135 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Formats lists the supported log record formats
+var Formats = []string{"text", "json"}
+
+// ParseLevel converts a level name (debug, info, warn, error) to a slog.Level
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q, must be one of debug, info, warn, error", name)
+	}
+	return level, nil
+}
+
+// New creates a logger writing records in the given format at or above level
+func New(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(format) {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q, must be one of %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// RotatingFile is an io.WriteCloser that rotates the underlying file once it
+// grows past a maximum size. Rotated files are renamed path.1, path.2, ... with
+// path.1 being the most recent; at most maxBackups are kept.
+type RotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// OpenRotatingFile opens path for logging. If appendMode is false any existing
+// file is truncated. A maxSize of 0 disables rotation.
+func OpenRotatingFile(path string, appendMode bool, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	rf := &RotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := rf.open(appendMode); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *RotatingFile) open(appendMode bool) error {
+	flags := os.O_CREATE | os.O_WRONLY
+	if appendMode {
+		flags |= os.O_APPEND
+	} else {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(rf.path, flags, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	rf.file = f
+	rf.size = info.Size()
+	return nil
+}
+
+// Write writes p to the file, rotating first if p would take it past maxSize
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.maxSize > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the existing backups along and starts a fresh file. If the
+// backups can't be shifted the original file is reopened so later writes
+// don't go to a closed file.
+func (rf *RotatingFile) rotate() error {
+	if err := rf.file.Close(); err != nil {
+		return errors.Join(err, rf.open(true))
+	}
+	if err := rf.shift(); err != nil {
+		return errors.Join(err, rf.open(true))
+	}
+	return rf.open(false)
+}
+
+// shift renames path to path.1, path.1 to path.2 and so on, keeping at most
+// maxBackups
+func (rf *RotatingFile) shift() error {
+	if rf.maxBackups == 0 {
+		return nil
+	}
+	for i := rf.maxBackups - 1; i > 0; i-- {
+		src := fmt.Sprintf("%s.%d", rf.path, i)
+		if _, err := os.Stat(src); err == nil {
+			if err := os.Rename(src, fmt.Sprintf("%s.%d", rf.path, i+1)); err != nil {
+				return err
+			}
+		}
+	}
+	return os.Rename(rf.path, rf.path+".1")
+}
+
+// Close closes the underlying file
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	return rf.file.Close()
+}
@@ -0,0 +1,146 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    slog.Level
+		wantErr bool
+	}{
+		{"debug", slog.LevelDebug, false},
+		{"info", slog.LevelInfo, false},
+		{"WARN", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"verbose", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLevel(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected level %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNewJSON(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := New(&buf, "json", slog.LevelWarn)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	log.Info("dropped")
+	log.Error("clone failed", "attempt", 3, "class", "timeout")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 record above the level, got %d", len(lines))
+	}
+	var record map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Expected JSON record, got %v", err)
+	}
+	if record["msg"] != "clone failed" || record["class"] != "timeout" || record["time"] == nil {
+		t.Errorf("Unexpected record: %v", record)
+	}
+
+	if _, err := New(&buf, "xml", slog.LevelInfo); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gitter.log")
+	if err := os.WriteFile(path, []byte("previous run\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Append mode keeps the previous run, which is rotated out along with the
+	// oldest backup once there are more than 2
+	rf, err := OpenRotatingFile(path, true, 20, 2)
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	for _, line := range []string{"first line\n", "second line\n", "third line\n"} {
+		if _, err := rf.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := rf.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	want := map[string]string{
+		path:        "third line\n",
+		path + ".1": "second line\n",
+		path + ".2": "first line\n",
+	}
+	for p, content := range want {
+		got, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", p, err)
+		}
+		if string(got) != content {
+			t.Errorf("Expected %s to contain %q, got %q", filepath.Base(p), content, got)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("Expected no more than 2 backups")
+	}
+
+	// Truncate mode starts afresh
+	rf, err = OpenRotatingFile(path, false, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	_ = rf.Close()
+	if info, _ := os.Stat(path); info.Size() != 0 {
+		t.Errorf("Expected truncated log file, got %d bytes", info.Size())
+	}
+}
+
+func TestRotatingFileRenameFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gitter.log")
+	// A directory in the way of the backup stops the rename
+	if err := os.MkdirAll(filepath.Join(path+".1", "busy"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	rf, err := OpenRotatingFile(path, false, 10, 1)
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	defer func() { _ = rf.Close() }()
+
+	if _, err := rf.Write([]byte("first line\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if _, err := rf.Write([]byte("second line\n")); err == nil {
+		t.Error("Expected an error when the backup can't be renamed")
+	}
+
+	// The original file is still open, so logging recovers once the backup
+	// can be written
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rf.Write([]byte("third line\n")); err != nil {
+		t.Fatalf("Expected writes to recover, got %v", err)
+	}
+	if got, _ := os.ReadFile(path + ".1"); string(got) != "first line\n" {
+		t.Errorf("Expected the backup to contain %q, got %q", "first line\n", got)
+	}
+}
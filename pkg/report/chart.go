@@ -0,0 +1,129 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+)
+
+// Chart dimensions, in SVG user units
+const (
+	chartWidth  = 800
+	chartHeight = 180
+	// chartLeft and chartBottom leave room for the axis labels
+	chartLeft   = 50
+	chartBottom = 20
+	chartTop    = 10
+)
+
+// Chart colours, matching the terminal UI
+const (
+	colourSuccess = "#2EB67D"
+	colourFail    = "#E01E5A"
+	colourP50     = "#1F77B4"
+	colourP99     = "#FF7F0E"
+)
+
+// plot maps times and values onto the chart area
+type plot struct {
+	start time.Time
+	span  time.Duration
+	max   float64
+}
+
+func (p plot) x(t time.Time) float64 {
+	if p.span <= 0 {
+		return chartLeft
+	}
+	return chartLeft + float64(t.Sub(p.start))/float64(p.span)*(chartWidth-chartLeft)
+}
+
+func (p plot) y(v float64) float64 {
+	if p.max <= 0 {
+		return chartHeight - chartBottom
+	}
+	return chartHeight - chartBottom - v/p.max*(chartHeight-chartBottom-chartTop)
+}
+
+// begin opens an SVG chart and draws the outages behind everything else
+func (r Report) begin(b *strings.Builder, p plot, title string) {
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" role="img" aria-label="%s">`, chartWidth, chartHeight, template.HTMLEscapeString(title))
+	for _, o := range r.Outages {
+		x := p.x(o.Start)
+		fmt.Fprintf(b, `<rect x="%.1f" y="%d" width="%.1f" height="%d" fill="%s" fill-opacity="0.12"><title>Outage %s for %s</title></rect>`,
+			x, chartTop, max(p.x(o.End)-x, 1), chartHeight-chartBottom-chartTop, colourFail,
+			o.Start.Format(time.TimeOnly), o.Duration().Round(time.Second))
+	}
+}
+
+// end draws the axes and closes the chart, labelling the top of the y axis
+// with yMax
+func (r Report) end(b *strings.Builder, yMax string) template.HTML {
+	base := chartHeight - chartBottom
+	fmt.Fprintf(b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#888"/>`, chartLeft, base, chartWidth, base)
+	fmt.Fprintf(b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#888"/>`, chartLeft, chartTop, chartLeft, base)
+	fmt.Fprintf(b, `<text x="%d" y="%d" font-size="11" text-anchor="end" dominant-baseline="hanging">%s</text>`, chartLeft-4, chartTop, yMax)
+	fmt.Fprintf(b, `<text x="%d" y="%d" font-size="11" text-anchor="end">0</text>`, chartLeft-4, base)
+	fmt.Fprintf(b, `<text x="%d" y="%d" font-size="11">%s</text>`, chartLeft, chartHeight-4, r.Start.Format(time.DateTime))
+	fmt.Fprintf(b, `<text x="%d" y="%d" font-size="11" text-anchor="end">%s</text>`, chartWidth, chartHeight-4, r.End.Format(time.DateTime))
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// OutcomeChart draws a bar for each bucket, successes stacked under failures
+func (r Report) OutcomeChart() template.HTML {
+	most := 0
+	for _, bk := range r.Buckets {
+		most = max(most, bk.Attempts())
+	}
+	p := plot{start: r.Start, span: r.End.Sub(r.Start), max: float64(most)}
+
+	var b strings.Builder
+	r.begin(&b, p, "Outcomes over time")
+	for _, bk := range r.Buckets {
+		x := p.x(bk.Start)
+		width := max(p.x(bk.End)-x-1, 1)
+		base := p.y(0)
+		ok := p.y(float64(bk.Success))
+		failed := p.y(float64(bk.Attempts()))
+		label := fmt.Sprintf("%s: %d ok, %d failed", bk.Start.Format(time.TimeOnly), bk.Success, bk.Fail)
+		if bk.Success > 0 {
+			fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s</title></rect>`, x, ok, width, base-ok, colourSuccess, label)
+		}
+		if bk.Fail > 0 {
+			fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s</title></rect>`, x, failed, width, ok-failed, colourFail, label)
+		}
+	}
+	return r.end(&b, fmt.Sprint(most))
+}
+
+// LatencyChart draws the p50 and p99 attempt duration of each bucket
+func (r Report) LatencyChart() template.HTML {
+	var slowest time.Duration
+	for _, bk := range r.Buckets {
+		slowest = max(slowest, bk.P99)
+	}
+	p := plot{start: r.Start, span: r.End.Sub(r.Start), max: slowest.Seconds()}
+
+	var b strings.Builder
+	r.begin(&b, p, "Attempt duration over time")
+	for _, line := range []struct {
+		colour string
+		value  func(Bucket) time.Duration
+	}{
+		{colourP99, func(bk Bucket) time.Duration { return bk.P99 }},
+		{colourP50, func(bk Bucket) time.Duration { return bk.P50 }},
+	} {
+		var points []string
+		for _, bk := range r.Buckets {
+			if bk.Attempts() == 0 {
+				continue
+			}
+			mid := bk.Start.Add(bk.End.Sub(bk.Start) / 2)
+			points = append(points, fmt.Sprintf("%.1f,%.1f", p.x(mid), p.y(line.value(bk).Seconds())))
+		}
+		fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`, strings.Join(points, " "), line.colour)
+	}
+	return r.end(&b, slowest.Round(time.Millisecond).String())
+}
@@ -0,0 +1,48 @@
+package report
+
+import (
+	"embed"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed templates
+var templates embed.FS
+
+// funcs are shared by the HTML and Markdown templates
+var funcs = map[string]any{
+	"duration": func(d time.Duration) string {
+		if d >= time.Minute {
+			return d.Round(time.Second).String()
+		}
+		return d.Round(time.Millisecond).String()
+	},
+	"datetime": func(t time.Time) string {
+		return t.Format(time.DateTime)
+	},
+	// cell makes text safe to put in a Markdown table
+	"cell": func(s string) string {
+		s = strings.ReplaceAll(s, "|", `\|`)
+		return strings.Join(strings.Fields(s), " ")
+	},
+}
+
+var (
+	htmlReport     = htmltemplate.Must(htmltemplate.New("report.html.tmpl").Funcs(funcs).ParseFS(templates, "templates/report.html.tmpl"))
+	markdownReport = template.Must(template.New("report.md.tmpl").Funcs(funcs).ParseFS(templates, "templates/report.md.tmpl"))
+)
+
+// WriteHTML renders the report as a single HTML page with inline charts and
+// no external resources
+func (r Report) WriteHTML(w io.Writer) error {
+	return htmlReport.Execute(w, r)
+}
+
+// WriteMarkdown renders the report as Markdown, with tables in place of the
+// charts
+func (r Report) WriteMarkdown(w io.Writer) error {
+	return markdownReport.Execute(w, r)
+}
@@ -0,0 +1,206 @@
+// Package report turns a recorded run into a report that can be attached to
+// a change ticket: a self-contained HTML page with charts, or Markdown.
+package report
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/kloudyuk/gitter/pkg/git"
+	"github.com/kloudyuk/gitter/pkg/history"
+	"github.com/kloudyuk/gitter/pkg/hooks"
+	"github.com/kloudyuk/gitter/pkg/runner"
+)
+
+// DefaultBuckets is how many slices of time the timeline is split into
+const DefaultBuckets = 60
+
+const (
+	FormatHTML     = "html"
+	FormatMarkdown = "markdown"
+)
+
+// Formats lists the supported report formats
+var Formats = []string{FormatHTML, FormatMarkdown}
+
+// Options configures a Report
+type Options struct {
+	// Buckets is how many slices of time the timeline is split into,
+	// defaulting to DefaultBuckets
+	Buckets int
+	// OutageAfter is how many consecutive failures start an outage,
+	// defaulting to hooks.DefaultOutageAfter
+	OutageAfter int
+}
+
+// Report summarises a run over time
+type Report struct {
+	Run     history.Run
+	Summary history.Summary
+	// Start and End bound the timeline
+	Start time.Time
+	End   time.Time
+	// Buckets split the run into equal slices of time, oldest first
+	Buckets []Bucket
+	// Outages are the periods the target was failing
+	Outages []Outage
+	// Classes break the failures down by error class, most common first
+	Classes   []ClassCount
+	Generated time.Time
+}
+
+// Bucket summarises the attempts that started within a slice of time
+type Bucket struct {
+	Start   time.Time
+	End     time.Time
+	Success int
+	Fail    int
+	P50     time.Duration
+	P99     time.Duration
+}
+
+// Attempts returns the number of attempts in the bucket
+func (b Bucket) Attempts() int {
+	return b.Success + b.Fail
+}
+
+// Outage is a period of consecutive failures
+type Outage struct {
+	Start    time.Time
+	End      time.Time
+	Failures int
+	Error    string
+	Class    string
+	// Ongoing is set if the run ended during the outage
+	Ongoing bool
+}
+
+// Duration returns how long the outage lasted
+func (o Outage) Duration() time.Duration {
+	return o.End.Sub(o.Start)
+}
+
+// ClassCount is the number of failures in an error class
+type ClassCount struct {
+	Class string
+	Count int
+	// Share is the percentage of failures in the class
+	Share float64
+}
+
+// New builds the report for run from its results. A run without a start
+// time, such as one read from a bare results file, is bounded by its
+// results instead.
+func New(run history.Run, results []history.Result, opts Options) Report {
+	if opts.Buckets <= 0 {
+		opts.Buckets = DefaultBuckets
+	}
+	if opts.OutageAfter <= 0 {
+		opts.OutageAfter = hooks.DefaultOutageAfter
+	}
+
+	r := Report{Run: run, Start: run.Start, End: run.End, Generated: time.Now()}
+	for _, res := range results {
+		if r.Start.IsZero() || res.Start.Before(r.Start) {
+			r.Start = res.Start
+		}
+		if end := res.Start.Add(res.Duration); end.After(r.End) {
+			r.End = end
+		}
+	}
+	if r.Run.Target == "" && len(results) > 0 {
+		r.Run.Target = results[0].Repo
+	}
+	if run.Summary != nil {
+		r.Summary = *run.Summary
+	} else {
+		r.Summary = history.Summarise(results, r.End.Sub(r.Start))
+	}
+
+	r.Buckets = buckets(results, r.Start, r.End, opts.Buckets)
+	r.Outages = outages(results, opts.OutageAfter)
+	for _, class := range slices.Sorted(maps.Keys(r.Summary.Classes)) {
+		count := r.Summary.Classes[class]
+		if class == "" {
+			class = string(git.ClassOther)
+		}
+		r.Classes = append(r.Classes, ClassCount{Class: class, Count: count, Share: float64(count) / float64(r.Summary.Fail) * 100})
+	}
+	slices.SortStableFunc(r.Classes, func(a, b ClassCount) int {
+		return cmp.Compare(b.Count, a.Count)
+	})
+	return r
+}
+
+// buckets splits the results into n equal slices of the time from start to
+// end
+func buckets(results []history.Result, start, end time.Time, n int) []Bucket {
+	span := end.Sub(start)
+	if len(results) == 0 || span <= 0 {
+		return nil
+	}
+	size := (span + time.Duration(n) - 1) / time.Duration(n)
+	bs := make([]Bucket, n)
+	durations := make([][]time.Duration, n)
+	for i := range bs {
+		bs[i].Start = start.Add(time.Duration(i) * size)
+		bs[i].End = bs[i].Start.Add(size)
+	}
+	for _, res := range results {
+		i := min(int(res.Start.Sub(start)/size), n-1)
+		if res.Failed() {
+			bs[i].Fail++
+		} else {
+			bs[i].Success++
+		}
+		durations[i] = append(durations[i], res.Duration)
+	}
+	for i := range bs {
+		slices.Sort(durations[i])
+		bs[i].P50 = runner.Percentile(durations[i], 50)
+		bs[i].P99 = runner.Percentile(durations[i], 99)
+	}
+	return bs
+}
+
+// outages finds the outages in the results the same way outage hooks do
+func outages(results []history.Result, after int) []Outage {
+	detector := hooks.NewOutageDetector(after)
+	var found []Outage
+	for _, res := range results {
+		a := runner.Attempt{ID: res.ID, Start: res.Start, Duration: res.Duration, Class: git.ErrorClass(res.Class)}
+		if res.Failed() {
+			a.Err = errors.New(res.Error)
+		}
+		e, ok := detector.Observe(a)
+		n := len(found)
+		switch {
+		case ok && e.Kind == hooks.OutageStart:
+			found = append(found, Outage{Start: e.Since, End: e.Time, Failures: e.Failures, Error: e.Error, Class: e.Class, Ongoing: true})
+		case ok && e.Kind == hooks.OutageEnd:
+			found[n-1].End = e.Time
+			found[n-1].Ongoing = false
+		case n > 0 && found[n-1].Ongoing && res.Failed():
+			found[n-1].End = res.Start.Add(res.Duration)
+			found[n-1].Failures++
+		}
+	}
+	return found
+}
+
+// Write renders the report in format
+func (r Report) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatHTML:
+		return r.WriteHTML(w)
+	case FormatMarkdown:
+		return r.WriteMarkdown(w)
+	default:
+		return fmt.Errorf("unknown report format %q", format)
+	}
+}
@@ -0,0 +1,120 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kloudyuk/gitter/pkg/history"
+)
+
+// results returns an attempt a second for each outcome, "." succeeding and
+// "x" failing with a timeout
+func results(start time.Time, outcomes string) []history.Result {
+	var rs []history.Result
+	for i, o := range outcomes {
+		r := history.Result{ID: i + 1, Start: start.Add(time.Duration(i) * time.Second), Duration: 500 * time.Millisecond}
+		if o == 'x' {
+			r.Error, r.Class = "i/o timeout", "timeout"
+		}
+		rs = append(rs, r)
+	}
+	return rs
+}
+
+func TestNew(t *testing.T) {
+	start := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	rs := results(start, "..xxxx....xx...xxx")
+	rs[3].Class = "refused"
+	r := New(history.Run{Target: "repo"}, rs, Options{Buckets: 3})
+
+	if !r.Start.Equal(start) || !r.End.Equal(start.Add(17500*time.Millisecond)) {
+		t.Errorf("Expected the timeline to be bounded by the results, got %s to %s", r.Start, r.End)
+	}
+	if r.Summary.Success != 9 || r.Summary.Fail != 9 {
+		t.Errorf("Expected 9 successes and 9 failures, got %+v", r.Summary)
+	}
+
+	if len(r.Buckets) != 3 {
+		t.Fatalf("Expected 3 buckets, got %d", len(r.Buckets))
+	}
+	for i, want := range [][2]int{{2, 4}, {4, 2}, {3, 3}} {
+		if b := r.Buckets[i]; b.Success != want[0] || b.Fail != want[1] {
+			t.Errorf("Expected bucket %d to have %d ok and %d failed, got %d and %d", i, want[0], want[1], b.Success, b.Fail)
+		}
+	}
+
+	if len(r.Outages) != 2 {
+		t.Fatalf("Expected 2 outages, got %+v", r.Outages)
+	}
+	if o := r.Outages[0]; !o.Start.Equal(start.Add(2*time.Second)) || o.Failures != 4 || o.Ongoing || o.Duration() != 4500*time.Millisecond {
+		t.Errorf("Expected an outage of 4 failures from 2s that recovered 4.5s later, got %+v", o)
+	}
+	if o := r.Outages[1]; !o.Ongoing || o.Failures != 3 || !o.End.Equal(r.End) {
+		t.Errorf("Expected an outage of 3 failures ongoing at the end, got %+v", o)
+	}
+
+	if len(r.Classes) != 2 || r.Classes[0].Class != "timeout" || r.Classes[0].Count != 8 || r.Classes[1].Share != 100.0/9 {
+		t.Errorf("Expected 8 timeouts then 1 refused, got %+v", r.Classes)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	start := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	run := history.Run{
+		ID:        "20261019-090000-abcd",
+		Target:    "https://git.example.com/<repo>.git",
+		Operation: "clone",
+		Config:    map[string]string{"interval": "1s"},
+	}
+	r := New(run, results(start, "...xxx..."), Options{})
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{FormatHTML, []string{
+			"<h1>https://git.example.com/&lt;repo&gt;.git</h1>",
+			"<svg",
+			"<polyline",
+			"<td>2026-10-19 09:00:03</td>",
+			"<td><code>--interval</code></td><td><code>1s</code></td>",
+		}},
+		{FormatMarkdown, []string{
+			"# Gitter report: https://git.example.com/<repo>.git",
+			"| 9 | 6 | 3 | 66.67% |",
+			"| 2026-10-19 09:00:03 | 2026-10-19 09:00:06 | 3.5s | 3 | timeout | i/o timeout |",
+			"| timeout | 3 | 100.0% |",
+			"| `--interval` | 1s |",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out strings.Builder
+			if err := r.Write(&out, tt.format); err != nil {
+				t.Fatalf("Failed to write report: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("Expected report to contain %s, got:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+
+	if err := r.Write(&strings.Builder{}, "pdf"); err == nil {
+		t.Error("Expected an error for an unknown format, got nil")
+	}
+}
+
+func TestEmptyReport(t *testing.T) {
+	r := New(history.Run{Target: "repo"}, nil, Options{})
+	var out strings.Builder
+	if err := r.WriteHTML(&out); err != nil {
+		t.Fatalf("Failed to write empty report: %v", err)
+	}
+	if !strings.Contains(out.String(), "No outages.") || strings.Contains(out.String(), "<svg") {
+		t.Errorf("Expected an empty report without charts, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,90 @@
+package runner
+
+import (
+	"sort"
+	"time"
+)
+
+// BreakdownStat summarises the attempts sharing one key, such as a backend
+// address or an operation
+type BreakdownStat struct {
+	Key     string
+	Success int
+	Fail    int
+	Total   time.Duration
+}
+
+// SuccessRate returns the percentage of attempts that succeeded
+func (b BreakdownStat) SuccessRate() float64 {
+	if b.Success+b.Fail == 0 {
+		return 0
+	}
+	return float64(b.Success) / float64(b.Success+b.Fail) * 100
+}
+
+// AverageDuration returns the mean attempt duration
+func (b BreakdownStat) AverageDuration() time.Duration {
+	if b.Success+b.Fail == 0 {
+		return 0
+	}
+	return b.Total / time.Duration(b.Success+b.Fail)
+}
+
+// BreakdownStats breaks attempt results down by a key
+type BreakdownStats struct {
+	stats map[string]*BreakdownStat
+}
+
+// NewBreakdownStats creates an empty BreakdownStats
+func NewBreakdownStats() *BreakdownStats {
+	return &BreakdownStats{stats: map[string]*BreakdownStat{}}
+}
+
+// Add records an attempt under key. Attempts with an empty key, such as
+// those that never reached a server, are ignored.
+func (bs *BreakdownStats) Add(key string, a Attempt) {
+	if key == "" {
+		return
+	}
+	s, ok := bs.stats[key]
+	if !ok {
+		s = &BreakdownStat{Key: key}
+		bs.stats[key] = s
+	}
+	if a.Err == nil {
+		s.Success++
+	} else {
+		s.Fail++
+	}
+	s.Total += a.Duration
+}
+
+// Lookup returns the stats kept under key
+func (bs *BreakdownStats) Lookup(key string) BreakdownStat {
+	if s, ok := bs.stats[key]; ok {
+		return *s
+	}
+	return BreakdownStat{Key: key}
+}
+
+// clone returns an independent copy of bs
+func (bs *BreakdownStats) clone() *BreakdownStats {
+	c := NewBreakdownStats()
+	for key, s := range bs.stats {
+		copied := *s
+		c.stats[key] = &copied
+	}
+	return c
+}
+
+// Get returns the stats for every key seen, in key order
+func (bs *BreakdownStats) Get() []BreakdownStat {
+	stats := make([]BreakdownStat, 0, len(bs.stats))
+	for _, s := range bs.stats {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Key < stats[j].Key
+	})
+	return stats
+}
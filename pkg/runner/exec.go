@@ -0,0 +1,117 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// DefaultCommand is the command run by the exec operation, so the git CLI
+// can be compared with go-git
+const DefaultCommand = "git clone --depth 1 {url} {dir}"
+
+// maxStderr bounds how much of a command's stderr is kept
+const maxStderr = 4096
+
+// CommandOperation runs a shell command for every attempt. {url} in Command
+// is replaced with the repository and {dir} with a new temporary directory,
+// which is removed afterwards.
+type CommandOperation struct {
+	Command string
+}
+
+// CommandError is returned when the command exits unsuccessfully
+type CommandError struct {
+	ExitCode int
+	// Stderr is the end of what the command wrote to stderr
+	Stderr string
+}
+
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("exit status %d", e.ExitCode)
+	if line := lastLine(e.Stderr); line != "" {
+		msg += ": " + line
+	}
+	return msg
+}
+
+func (c *CommandOperation) Execute(ctx context.Context, repo string) error {
+	dir, err := os.MkdirTemp("", "gitter-exec-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	line := strings.NewReplacer("{url}", shellQuote(repo), "{dir}", shellQuote(dir)).Replace(c.Command)
+	cmd := exec.CommandContext(ctx, "sh", "-c", line)
+	stderr := &tailWriter{max: maxStderr}
+	cmd.Stderr = stderr
+	// Children of the shell may keep stderr open after it is killed
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &CommandError{ExitCode: exitErr.ExitCode(), Stderr: strings.TrimSpace(string(stderr.buf))}
+	}
+	return err
+}
+
+// tailWriter keeps the last max bytes written to it, so a noisy command
+// can't use unbounded memory
+type tailWriter struct {
+	buf []byte
+	max int
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(p) >= w.max {
+		p = p[len(p)-w.max:]
+		w.buf = w.buf[:0]
+	} else if drop := len(w.buf) + len(p) - w.max; drop > 0 {
+		w.buf = w.buf[:copy(w.buf, w.buf[drop:])]
+	}
+	w.buf = append(w.buf, p...)
+	return n, nil
+}
+
+// lastLine returns the last non-empty line of s
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
+	}
+	return strings.TrimSpace(s)
+}
+
+// shellQuote quotes s as a single sh word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func init() {
+	command := DefaultCommand
+	RegisterOperation(Registration{
+		Name:        "exec",
+		Description: "run --exec-command, such as the git CLI",
+		Flags: func(fs *pflag.FlagSet) {
+			fs.StringVar(&command, "exec-command", DefaultCommand, "shell command run by --op exec; {url} is the repository and {dir} a temporary directory")
+		},
+		New: func(cfg OperationConfig) (CloneOperation, error) {
+			if strings.TrimSpace(command) == "" {
+				return nil, errors.New("exec-command must not be empty")
+			}
+			return &CommandOperation{Command: command}, nil
+		},
+	})
+}
@@ -0,0 +1,258 @@
+package runner
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoadProfile gives the target rate of clone starts per second at a point
+// in the run
+type LoadProfile interface {
+	Rate(elapsed time.Duration) float64
+	String() string
+}
+
+// RampProfile increases the rate linearly from From to To over Over, then
+// holds it at To
+type RampProfile struct {
+	From, To float64
+	Over     time.Duration
+}
+
+func (p RampProfile) Rate(elapsed time.Duration) float64 {
+	if elapsed >= p.Over {
+		return p.To
+	}
+	return p.From + (p.To-p.From)*float64(elapsed)/float64(p.Over)
+}
+
+func (p RampProfile) String() string {
+	return fmt.Sprintf("ramp %g→%g/s over %s", p.From, p.To, p.Over)
+}
+
+// Validate checks the rates are usable and Over is positive
+func (p RampProfile) Validate() error {
+	if err := checkRates(map[string]float64{"from": p.From, "to": p.To}); err != nil {
+		return err
+	}
+	if p.Over <= 0 {
+		return fmt.Errorf("over must be positive, got %s", p.Over)
+	}
+	return nil
+}
+
+// StepProfile starts at Start and adds Step every Every, up to Max if set
+type StepProfile struct {
+	Start, Step float64
+	Every       time.Duration
+	Max         float64
+}
+
+func (p StepProfile) Rate(elapsed time.Duration) float64 {
+	rate := p.Start + p.Step*float64(elapsed/p.Every)
+	if p.Max > 0 {
+		rate = math.Min(rate, p.Max)
+	}
+	return rate
+}
+
+func (p StepProfile) String() string {
+	s := fmt.Sprintf("step %g/s +%g every %s", p.Start, p.Step, p.Every)
+	if p.Max > 0 {
+		s += fmt.Sprintf(" up to %g/s", p.Max)
+	}
+	return s
+}
+
+// Validate checks the rates are usable and Every is positive
+func (p StepProfile) Validate() error {
+	if err := checkRates(map[string]float64{"start": p.Start, "step": p.Step, "max": p.Max}); err != nil {
+		return err
+	}
+	if p.Every <= 0 {
+		return fmt.Errorf("every must be positive, got %s", p.Every)
+	}
+	return nil
+}
+
+// SpikeProfile runs at Base, jumping to Peak for Length at the start of
+// every Every
+type SpikeProfile struct {
+	Base, Peak float64
+	Every      time.Duration
+	Length     time.Duration
+}
+
+func (p SpikeProfile) Rate(elapsed time.Duration) float64 {
+	if elapsed%p.Every < p.Length {
+		return p.Peak
+	}
+	return p.Base
+}
+
+func (p SpikeProfile) String() string {
+	return fmt.Sprintf("spike %g/s, %g/s for %s every %s", p.Base, p.Peak, p.Length, p.Every)
+}
+
+// Validate checks the rates are usable and each spike fits in its period
+func (p SpikeProfile) Validate() error {
+	if err := checkRates(map[string]float64{"base": p.Base, "peak": p.Peak}); err != nil {
+		return err
+	}
+	switch {
+	case p.Every <= 0:
+		return fmt.Errorf("every must be positive, got %s", p.Every)
+	case p.Length <= 0:
+		return fmt.Errorf("for must be positive, got %s", p.Length)
+	case p.Length > p.Every:
+		return fmt.Errorf("for must not be longer than every")
+	}
+	return nil
+}
+
+// checkRates rejects negative or non-finite rates, naming the first bad one
+func checkRates(rates map[string]float64) error {
+	for _, name := range slices.Sorted(maps.Keys(rates)) {
+		if r := rates[name]; r < 0 || math.IsNaN(r) || math.IsInf(r, 0) {
+			return fmt.Errorf("%s must be a non-negative number of clones per second, got %g", name, r)
+		}
+	}
+	return nil
+}
+
+// validator is implemented by load profiles that can check their settings
+type validator interface {
+	Validate() error
+}
+
+// ParseLoadProfile parses a profile written as a kind followed by
+// comma-separated settings:
+//
+//	ramp,from=1,to=10,over=5m
+//	step,start=1,step=1,every=2m,max=20
+//	spike,base=1,peak=20,every=10m,for=30s
+func ParseLoadProfile(s string) (LoadProfile, error) {
+	kind, rest, _ := strings.Cut(s, ",")
+	settings := map[string]string{}
+	if rest != "" {
+		for _, kv := range strings.Split(rest, ",") {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid load profile setting %q, must be key=value", kv)
+			}
+			settings[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	ps := profileSettings{settings: settings}
+
+	var p LoadProfile
+	switch kind {
+	case "ramp":
+		p = RampProfile{From: ps.rate("from", false), To: ps.rate("to", true), Over: ps.duration("over")}
+	case "step":
+		p = StepProfile{Start: ps.rate("start", true), Step: ps.rate("step", true), Every: ps.duration("every"), Max: ps.rate("max", false)}
+	case "spike":
+		p = SpikeProfile{Base: ps.rate("base", false), Peak: ps.rate("peak", true), Every: ps.duration("every"), Length: ps.duration("for")}
+	default:
+		return nil, fmt.Errorf("unknown load profile %q, must be one of ramp, step, spike", kind)
+	}
+	if ps.err != nil {
+		return nil, fmt.Errorf("invalid %s profile: %w", kind, ps.err)
+	}
+	for k := range settings {
+		if !ps.used[k] {
+			return nil, fmt.Errorf("invalid %s profile: unknown setting %q", kind, k)
+		}
+	}
+	if err := p.(validator).Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s profile: %w", kind, err)
+	}
+	return p, nil
+}
+
+// profileSettings reads settings for ParseLoadProfile, keeping the first error
+type profileSettings struct {
+	settings map[string]string
+	used     map[string]bool
+	err      error
+}
+
+func (ps *profileSettings) get(key string, required bool) (string, bool) {
+	if ps.used == nil {
+		ps.used = map[string]bool{}
+	}
+	ps.used[key] = true
+	v, ok := ps.settings[key]
+	if !ok && required && ps.err == nil {
+		ps.err = fmt.Errorf("%s is required", key)
+	}
+	return v, ok
+}
+
+func (ps *profileSettings) rate(key string, required bool) float64 {
+	v, ok := ps.get(key, required)
+	if !ok {
+		return 0
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if (err != nil || f < 0 || math.IsNaN(f) || math.IsInf(f, 0)) && ps.err == nil {
+		ps.err = fmt.Errorf("%s must be a non-negative number of clones per second, got %q", key, v)
+	}
+	return f
+}
+
+func (ps *profileSettings) duration(key string) time.Duration {
+	v, ok := ps.get(key, true)
+	if !ok {
+		return 0
+	}
+	d, err := time.ParseDuration(v)
+	if (err != nil || d <= 0) && ps.err == nil {
+		ps.err = fmt.Errorf("%s must be a positive duration, got %q", key, v)
+	}
+	return d
+}
+
+// rateMeter measures how often events happen over a sliding window
+type rateMeter struct {
+	mu     sync.Mutex
+	window time.Duration
+	times  []time.Time
+}
+
+func newRateMeter(window time.Duration) *rateMeter {
+	return &rateMeter{window: window}
+}
+
+// add records an event at t, dropping events that have left the window so
+// the meter stays small even if rate is never called
+func (r *rateMeter) add(t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.times = append(r.times, t)
+	r.prune(t)
+}
+
+// rate returns the events per second over the window ending at now
+func (r *rateMeter) rate(now time.Time) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prune(now)
+	return float64(len(r.times)) / r.window.Seconds()
+}
+
+// prune drops events before the window ending at now, with r.mu held
+func (r *rateMeter) prune(now time.Time) {
+	cutoff := now.Add(-r.window)
+	i := 0
+	for i < len(r.times) && r.times[i].Before(cutoff) {
+		i++
+	}
+	r.times = slices.Delete(r.times, 0, i)
+}
@@ -0,0 +1,72 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/kloudyuk/gitter/pkg/git"
+)
+
+// logAttempt writes a structured record for a completed attempt
+func (r *Runner) logAttempt(a Attempt) {
+	if r.opts.Log == nil {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("target", git.RedactURL(a.Repo)),
+		slog.Int("attempt", a.ID),
+		slog.Duration("duration", a.Duration),
+	}
+	if a.Op != "" {
+		attrs = append(attrs, slog.String("op", string(a.Op)))
+	}
+	if a.Handshake > 0 {
+		attrs = append(attrs,
+			slog.Duration("handshake", a.Handshake),
+			slog.Duration("transfer", a.Transfer),
+		)
+	}
+	if r.opts.LoadProfile != nil {
+		attrs = append(attrs,
+			slog.Float64("target_rate", a.TargetRate),
+			slog.Float64("achieved_rate", r.achieved.rate(time.Now())),
+		)
+	}
+	if a.Retries > 0 {
+		attrs = append(attrs, slog.Int("retries", a.Retries))
+		if a.FirstErr != nil {
+			attrs = append(attrs, slog.String("first_error", a.FirstErr.Error()))
+		}
+	}
+	if a.Backend != "" {
+		attrs = append(attrs, slog.String("backend", a.Backend))
+	}
+	if a.Family != git.IPAny {
+		attrs = append(attrs, slog.String("ip_family", a.Family.String()))
+	}
+	if a.Cert != nil {
+		attrs = append(attrs, slog.Group("tls",
+			slog.String("subject", a.Cert.Subject),
+			slog.String("issuer", a.Cert.Issuer),
+			slog.Time("not_after", a.Cert.NotAfter),
+		))
+	}
+	if a.Err == nil {
+		r.opts.Log.LogAttrs(context.Background(), slog.LevelInfo, "clone succeeded", attrs...)
+		return
+	}
+	attrs = append(attrs,
+		slog.String("class", string(a.Class)),
+		slog.String("error", a.Err.Error()),
+	)
+	var cmdErr *CommandError
+	if errors.As(a.Err, &cmdErr) {
+		attrs = append(attrs,
+			slog.Int("exit_code", cmdErr.ExitCode),
+			slog.String("stderr", cmdErr.Stderr),
+		)
+	}
+	r.opts.Log.LogAttrs(context.Background(), slog.LevelError, "clone failed", attrs...)
+}
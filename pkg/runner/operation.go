@@ -0,0 +1,122 @@
+package runner
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kloudyuk/gitter/pkg/demo"
+	"github.com/kloudyuk/gitter/pkg/git"
+)
+
+// CloneOperation defines the interface for clone operations
+type CloneOperation interface {
+	Execute(ctx context.Context, repo string) error
+}
+
+// WorkloadOperation is a CloneOperation that can also run the other git
+// operations a mixed workload uses
+type WorkloadOperation interface {
+	CloneOperation
+	Run(ctx context.Context, op git.Operation, repo string) error
+}
+
+// NewGitOperation creates an operation running real git operations with
+// opts. With dualStack set, attempts alternate between IPv4 and IPv6 and
+// opts.IPFamily is ignored.
+func NewGitOperation(opts git.Options, dualStack bool) (WorkloadOperation, error) {
+	if !dualStack {
+		client, err := git.NewClient(opts)
+		if err != nil {
+			return nil, err
+		}
+		return &RealCloneOperation{Client: client}, nil
+	}
+	v4, v6 := opts, opts
+	v4.IPFamily, v6.IPFamily = git.IPv4, git.IPv6
+	dual := &DualStackCloneOperation{}
+	var err error
+	if dual.IPv4, err = git.NewClient(v4); err != nil {
+		return nil, err
+	}
+	if dual.IPv6, err = git.NewClient(v6); err != nil {
+		return nil, err
+	}
+	return dual, nil
+}
+
+// RealCloneOperation implements actual git cloning. If Client is nil a
+// Client with the default options is created on first use.
+type RealCloneOperation struct {
+	Client *git.Client
+
+	once     sync.Once
+	fallback *git.Client
+	err      error
+}
+
+func (r *RealCloneOperation) Execute(ctx context.Context, repo string) error {
+	return r.Run(ctx, git.OpClone, repo)
+}
+
+// Run performs op against repo, reusing the Client's connections and fetched
+// repositories between calls
+func (r *RealCloneOperation) Run(ctx context.Context, op git.Operation, repo string) error {
+	client, err := r.client()
+	if err != nil {
+		return err
+	}
+	return client.Run(ctx, op, repo)
+}
+
+// client returns Client, or the default one shared by every call
+func (r *RealCloneOperation) client() (*git.Client, error) {
+	if r.Client != nil {
+		return r.Client, nil
+	}
+	r.once.Do(func() {
+		r.fallback, r.err = git.NewClient(git.Options{})
+	})
+	return r.fallback, r.err
+}
+
+// DualStackCloneOperation alternates real clones between IPv4 and IPv6
+type DualStackCloneOperation struct {
+	IPv4 *git.Client
+	IPv6 *git.Client
+	next atomic.Uint64
+}
+
+func (d *DualStackCloneOperation) Execute(ctx context.Context, repo string) error {
+	if d.next.Add(1)%2 == 1 {
+		return d.IPv4.Clone(ctx, repo)
+	}
+	return d.IPv6.Clone(ctx, repo)
+}
+
+// Run performs op against repo, alternating between the IPv4 and IPv6
+// Clients like Execute
+func (d *DualStackCloneOperation) Run(ctx context.Context, op git.Operation, repo string) error {
+	if d.next.Add(1)%2 == 1 {
+		return d.IPv4.Run(ctx, op, repo)
+	}
+	return d.IPv6.Run(ctx, op, repo)
+}
+
+// DemoCloneOperation implements simulated git cloning. If Simulator is nil the
+// package defaults are used.
+type DemoCloneOperation struct {
+	Simulator *demo.Simulator
+}
+
+func (d *DemoCloneOperation) Execute(ctx context.Context, repo string) error {
+	if d.Simulator != nil {
+		return d.Simulator.Clone(ctx, repo)
+	}
+	return demo.Clone(ctx, repo)
+}
+
+// Run simulates any operation the same way as a clone
+func (d *DemoCloneOperation) Run(ctx context.Context, op git.Operation, repo string) error {
+	return d.Execute(ctx, repo)
+}
@@ -0,0 +1,167 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/kloudyuk/gitter/pkg/demo"
+	"github.com/kloudyuk/gitter/pkg/git"
+
+	"github.com/spf13/pflag"
+)
+
+// DefaultOperation is the operation used when none is selected
+const DefaultOperation = "clone"
+
+// OperationConfig holds the settings shared by every operation
+type OperationConfig struct {
+	// Git configures operations that use go-git
+	Git git.Options
+	// DualStack alternates attempts between IPv4 and IPv6
+	DualStack bool
+	// Seed and Scenario drive the simulated server of the demo operation
+	Seed     uint64
+	Scenario *demo.Scenario
+}
+
+// Registration describes a named operation that can be selected with --op
+type Registration struct {
+	Name        string
+	Description string
+	// Flags, if set, adds the operation's own flags. Flag names should be
+	// prefixed with the operation's name so they don't clash.
+	Flags func(fs *pflag.FlagSet)
+	// New creates the operation once flags have been parsed
+	New func(cfg OperationConfig) (CloneOperation, error)
+}
+
+// Registry holds the operations that can be selected by name
+type Registry struct {
+	mu  sync.RWMutex
+	ops map[string]Registration
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{ops: map[string]Registration{}}
+}
+
+// DefaultRegistry holds the built-in operations and those added with
+// RegisterOperation
+var DefaultRegistry = NewRegistry()
+
+// Register makes an operation available by name, panicking if the name is
+// already taken
+func (reg *Registry) Register(r Registration) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if r.Name == "" || r.New == nil {
+		panic("runner: operation registration needs a name and New")
+	}
+	if _, dup := reg.ops[r.Name]; dup {
+		panic(fmt.Sprintf("runner: operation %q registered twice", r.Name))
+	}
+	reg.ops[r.Name] = r
+}
+
+// Lookup returns the operation registered under name
+func (reg *Registry) Lookup(name string) (Registration, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	r, ok := reg.ops[name]
+	return r, ok
+}
+
+// Operations returns every registered operation, in name order
+func (reg *Registry) Operations() []Registration {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	regs := make([]Registration, 0, len(reg.ops))
+	for _, r := range reg.ops {
+		regs = append(regs, r)
+	}
+	sort.Slice(regs, func(i, j int) bool {
+		return regs[i].Name < regs[j].Name
+	})
+	return regs
+}
+
+// New creates the operation registered under name
+func (reg *Registry) New(name string, cfg OperationConfig) (CloneOperation, error) {
+	r, ok := reg.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown operation %q", name)
+	}
+	return r.New(cfg)
+}
+
+// RegisterOperation makes an operation available by name in
+// DefaultRegistry. It is meant to be called from init functions and panics
+// if the name is already taken.
+func RegisterOperation(r Registration) {
+	DefaultRegistry.Register(r)
+}
+
+// LookupOperation returns the operation registered under name in
+// DefaultRegistry
+func LookupOperation(name string) (Registration, bool) {
+	return DefaultRegistry.Lookup(name)
+}
+
+// Operations returns every operation in DefaultRegistry, in name order
+func Operations() []Registration {
+	return DefaultRegistry.Operations()
+}
+
+// NewOperation creates the operation registered under name in
+// DefaultRegistry
+func NewOperation(name string, cfg OperationConfig) (CloneOperation, error) {
+	return DefaultRegistry.New(name, cfg)
+}
+
+// singleOperation runs one git operation for every attempt, while still
+// supporting mixed workloads
+type singleOperation struct {
+	WorkloadOperation
+	op git.Operation
+}
+
+func (s singleOperation) Execute(ctx context.Context, repo string) error {
+	return s.Run(ctx, s.op, repo)
+}
+
+func init() {
+	descriptions := map[git.Operation]string{
+		git.OpClone:     "shallow clone (depth 1) into memory",
+		git.OpFullClone: "clone with full history into memory",
+		git.OpLsRemote:  "list the remote's refs without fetching objects",
+		git.OpFetch:     "fetch into a repository cloned on first use",
+		git.OpPush:      "force-push a new commit to --push-ref",
+	}
+	for _, op := range git.Operations {
+		RegisterOperation(Registration{
+			Name:        string(op),
+			Description: descriptions[op],
+			New: func(cfg OperationConfig) (CloneOperation, error) {
+				w, err := NewGitOperation(cfg.Git, cfg.DualStack)
+				if err != nil || op == git.OpClone {
+					return w, err
+				}
+				return singleOperation{WorkloadOperation: w, op: op}, nil
+			},
+		})
+	}
+	RegisterOperation(Registration{
+		Name:        "demo",
+		Description: "simulated clones, without a server",
+		New: func(cfg OperationConfig) (CloneOperation, error) {
+			sim, err := demo.NewSimulator(cfg.Seed, cfg.Scenario)
+			if err != nil {
+				return nil, err
+			}
+			return &DemoCloneOperation{Simulator: sim}, nil
+		},
+	})
+}
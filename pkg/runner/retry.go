@@ -0,0 +1,109 @@
+package runner
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/kloudyuk/gitter/pkg/git"
+)
+
+// DefaultRetryClasses are the error classes a CI client would usually retry
+var DefaultRetryClasses = []git.ErrorClass{
+	git.ClassTimeout,
+	git.ClassDNS,
+	git.ClassRefused,
+	git.ClassNetwork,
+	git.ClassServer,
+}
+
+// RetryPolicy controls how a failed clone is retried before the attempt is
+// counted as failed. The zero value never retries.
+type RetryPolicy struct {
+	MaxRetries int
+	// InitialBackoff is the delay before the first retry, doubling for each
+	// retry after that up to MaxBackoff. Delays are jittered between half and
+	// all of the computed value.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Classes lists the error classes worth retrying
+	Classes []git.ErrorClass
+}
+
+// retryable reports whether a failure of the given class should be retried
+func (p RetryPolicy) retryable(class git.ErrorClass) bool {
+	return slices.Contains(p.Classes, class)
+}
+
+// backoff returns the delay before the given retry, counting from zero.
+// Without a MaxBackoff the delay stops doubling before it would overflow.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := p.InitialBackoff
+	for range retry {
+		if (p.MaxBackoff > 0 && d >= p.MaxBackoff) || d > math.MaxInt64/2 {
+			break
+		}
+		d *= 2
+	}
+	if p.MaxBackoff > 0 {
+		d = min(d, p.MaxBackoff)
+	}
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int64N(int64(d-half)))
+}
+
+// RetryStats compares the outcome of each attempt's first try with its final
+// outcome after retries
+type RetryStats struct {
+	FirstSuccess int
+	FirstFail    int
+	FinalSuccess int
+	FinalFail    int
+	// Masked counts attempts that failed at first but succeeded on a retry
+	Masked  int
+	Retries int
+}
+
+// NewRetryStats creates an empty RetryStats
+func NewRetryStats() *RetryStats {
+	return &RetryStats{}
+}
+
+// Add records a completed attempt
+func (rs *RetryStats) Add(a Attempt) {
+	if a.FirstErr == nil {
+		rs.FirstSuccess++
+	} else {
+		rs.FirstFail++
+	}
+	if a.Err == nil {
+		rs.FinalSuccess++
+		if a.FirstErr != nil {
+			rs.Masked++
+		}
+	} else {
+		rs.FinalFail++
+	}
+	rs.Retries += a.Retries
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total) * 100
+}
+
+// FirstTrySuccessRate returns the percentage of attempts whose first try succeeded
+func (rs *RetryStats) FirstTrySuccessRate() float64 {
+	return percent(rs.FirstSuccess, rs.FirstSuccess+rs.FirstFail)
+}
+
+// FinalSuccessRate returns the percentage of attempts that succeeded after retries
+func (rs *RetryStats) FinalSuccessRate() float64 {
+	return percent(rs.FinalSuccess, rs.FinalSuccess+rs.FinalFail)
+}
@@ -0,0 +1,383 @@
+// Package runner repeatedly runs git operations against a server and
+// collects the results, independently of how they are shown. The TUI and
+// headless output are both built on it, and it can be driven directly from
+// Go test harnesses.
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kloudyuk/gitter/pkg/git"
+	"github.com/kloudyuk/gitter/pkg/workload"
+)
+
+// Attempt records the outcome of a single clone operation
+type Attempt struct {
+	ID       int
+	Start    time.Time
+	Duration time.Duration
+	Err      error
+	Class    git.ErrorClass
+	// Handshake and Transfer split Duration for real clones
+	Handshake time.Duration
+	Transfer  time.Duration
+	// Cert is the certificate presented by an https server
+	Cert *git.CertInfo
+	// Backend is the server IP the clone connected to
+	Backend string
+	// Family is the address family the clone was restricted to, if any
+	Family git.IPFamily
+	// Retries is how many times the clone was retried, and FirstErr the
+	// outcome of the first try. Err, Class and the timings describe the
+	// final try while Duration covers every try and backoff.
+	Retries  int
+	FirstErr error
+	// TargetRate is the load profile's rate when the attempt started
+	TargetRate float64
+	// Op and Repo are the operation and repository picked from a mixed
+	// workload; Op is empty otherwise
+	Op   git.Operation
+	Repo string
+	// Spans time the phases of the final try, where the operation records
+	// them
+	Spans []git.Span
+}
+
+// Options configures a Runner
+type Options struct {
+	// Repo is the repository cloned unless the workload picks another
+	Repo string
+	// Interval is the delay between attempts, which run one at a time. It
+	// is ignored when LoadProfile is set.
+	Interval time.Duration
+	// Timeout bounds each try of an attempt. Zero means no timeout.
+	Timeout time.Duration
+	// Retry is applied to every attempt. The zero value never retries.
+	Retry RetryPolicy
+	// LoadProfile, when set, starts attempts at a varying rate instead of
+	// one every Interval, so they may overlap
+	LoadProfile LoadProfile
+	// MaxInFlight bounds how many attempts a load profile runs at once.
+	// Starts due while that many are running are skipped and counted in
+	// Stats.Skipped. Zero means no limit.
+	MaxInFlight int
+	// Workload, when set, picks the operation and repository for each
+	// attempt. The runner's operation must implement WorkloadOperation.
+	Workload *workload.Workload
+	// Results, if set, receives every completed attempt
+	Results chan<- Attempt
+	// OnResult, if set, is called with every completed attempt before it is
+	// sent to Results. Calls never overlap.
+	OnResult func(Attempt)
+	// Instrument, if set, is called as each attempt starts with its ID,
+	// operation, repository and start time. The attempt runs with the
+	// returned context, and end is called when it completes, including
+	// attempts cut short by the runner stopping.
+	Instrument func(ctx context.Context, a Attempt) (_ context.Context, end func(Attempt))
+	// Windows are the spans recent stats are kept for, as well as the
+	// lifetime totals. Nil uses DefaultWindows.
+	Windows []time.Duration
+	// Log receives a record for every attempt. Nil disables logging.
+	Log *slog.Logger
+}
+
+// Runner runs an operation repeatedly, collecting stats on the results
+type Runner struct {
+	operation CloneOperation
+	opts      Options
+	attempts  atomic.Int64
+	// target is the profile's current rate, stored as float64 bits
+	target   atomic.Uint64
+	inFlight atomic.Int64
+	skipped  atomic.Int64
+	// deliver serialises calls to OnResult
+	deliver sync.Mutex
+
+	mu         sync.Mutex
+	start      time.Time
+	success    int
+	fail       int
+	retries    *RetryStats
+	backends   *BreakdownStats
+	families   *BreakdownStats
+	operations *BreakdownStats
+	achieved   *rateMeter
+	windows    []*Window
+}
+
+// New creates a Runner for operation
+func New(operation CloneOperation, opts Options) (*Runner, error) {
+	if opts.LoadProfile == nil && opts.Interval <= 0 {
+		return nil, fmt.Errorf("interval must be positive, got %v", opts.Interval)
+	}
+	if v, ok := opts.LoadProfile.(validator); ok {
+		if err := v.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid load profile %s: %w", opts.LoadProfile, err)
+		}
+	}
+	if opts.Timeout < 0 {
+		return nil, fmt.Errorf("timeout must not be negative, got %v", opts.Timeout)
+	}
+	if opts.MaxInFlight < 0 {
+		return nil, fmt.Errorf("max in flight must not be negative, got %d", opts.MaxInFlight)
+	}
+	if opts.Workload != nil {
+		if _, ok := operation.(WorkloadOperation); !ok {
+			return nil, fmt.Errorf("%T does not support mixed workloads", operation)
+		}
+	}
+	if opts.Windows == nil {
+		opts.Windows = DefaultWindows
+	}
+	windows := make([]*Window, 0, len(opts.Windows))
+	for _, span := range opts.Windows {
+		if span <= 0 {
+			return nil, fmt.Errorf("windows must be positive, got %v", span)
+		}
+		windows = append(windows, NewWindow(span))
+	}
+	return &Runner{
+		operation:  operation,
+		opts:       opts,
+		retries:    NewRetryStats(),
+		backends:   NewBreakdownStats(),
+		families:   NewBreakdownStats(),
+		operations: NewBreakdownStats(),
+		achieved:   newRateMeter(10 * time.Second),
+		windows:    windows,
+	}, nil
+}
+
+// Run runs attempts until ctx is done, then waits for any still in flight.
+// Without a load profile attempts run one at a time every interval; with
+// one they are started at the profile's rate and may overlap. Attempts cut
+// short by ctx are not reported. Run always returns ctx's error.
+func (r *Runner) Run(ctx context.Context) error {
+	r.mu.Lock()
+	r.start = time.Now()
+	r.mu.Unlock()
+
+	var wg sync.WaitGroup
+	if r.opts.LoadProfile != nil {
+		r.runProfile(ctx, &wg)
+	} else {
+		r.runInterval(ctx)
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// TargetRate returns the rate the load profile is currently asking for
+func (r *Runner) TargetRate() float64 {
+	return math.Float64frombits(r.target.Load())
+}
+
+// InFlight returns how many attempts are currently running
+func (r *Runner) InFlight() int {
+	return int(r.inFlight.Load())
+}
+
+// Skipped returns how many starts the load profile skipped because
+// MaxInFlight attempts were already running
+func (r *Runner) Skipped() int {
+	return int(r.skipped.Load())
+}
+
+// runInterval runs one attempt after another, waiting for the interval
+// between them
+func (r *Runner) runInterval(ctx context.Context) {
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+	for {
+		r.report(ctx, r.run(ctx))
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// maxProfileWait is the longest runProfile sleeps before re-reading the rate
+const maxProfileWait = 100 * time.Millisecond
+
+// runProfile starts attempts at the load profile's rate until ctx is done,
+// skipping starts while MaxInFlight attempts are running
+func (r *Runner) runProfile(ctx context.Context, wg *sync.WaitGroup) {
+	var slots chan struct{}
+	if r.opts.MaxInFlight > 0 {
+		slots = make(chan struct{}, r.opts.MaxInFlight)
+	}
+	start := time.Now()
+	last, lastRate := start, r.opts.LoadProfile.Rate(0)
+	var due float64
+	for {
+		// Integrate the rate since the last wake-up so a changing rate is
+		// followed within one wake-up, but don't try to catch up on more than
+		// a second's worth of starts after falling behind
+		now := time.Now()
+		rate := r.opts.LoadProfile.Rate(now.Sub(start))
+		r.target.Store(math.Float64bits(rate))
+		due += (lastRate + rate) / 2 * now.Sub(last).Seconds()
+		due = math.Min(due, math.Max(rate, 1))
+		last, lastRate = now, rate
+
+		for ; due >= 1; due-- {
+			if !acquire(slots) {
+				r.skipped.Add(1)
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer release(slots)
+				r.report(ctx, r.run(ctx))
+			}()
+		}
+
+		wait := maxProfileWait
+		if rate > 0 {
+			wait = min(wait, time.Duration((1-due)/rate*float64(time.Second)))
+		}
+		if !sleep(ctx, wait) {
+			return
+		}
+	}
+}
+
+// acquire takes one of slots for an attempt, returning false if they are all
+// taken. A nil slots is unlimited.
+func acquire(slots chan struct{}) bool {
+	if slots == nil {
+		return true
+	}
+	select {
+	case slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// release returns a slot taken by acquire
+func release(slots chan struct{}) {
+	if slots != nil {
+		<-slots
+	}
+}
+
+// sleep waits for d, returning false if ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// run performs a single timed attempt, retrying according to the retry
+// policy
+func (r *Runner) run(ctx context.Context) Attempt {
+	r.inFlight.Add(1)
+	defer r.inFlight.Add(-1)
+	a := Attempt{ID: int(r.attempts.Add(1)), Start: time.Now(), TargetRate: r.TargetRate(), Repo: r.opts.Repo}
+	if r.opts.Workload != nil {
+		var repo string
+		if a.Op, repo = r.opts.Workload.Pick(); repo != "" {
+			a.Repo = repo
+		}
+	}
+	if r.opts.Instrument != nil {
+		var end func(Attempt)
+		ctx, end = r.opts.Instrument(ctx, a)
+		defer func() { end(a) }()
+	}
+
+	for {
+		var trace git.Trace
+		a.Err = r.try(ctx, &trace, a.Op, a.Repo)
+		a.Class = git.Classify(a.Err)
+		timings := trace.Snapshot()
+		a.Handshake = timings.Handshake
+		a.Transfer = timings.Transfer
+		a.Cert = timings.Cert
+		a.Backend = timings.Backend
+		a.Family = timings.Family
+		a.Spans = timings.Spans
+		if a.Retries == 0 {
+			a.FirstErr = a.Err
+		}
+		if a.Err == nil || a.Retries >= r.opts.Retry.MaxRetries || !r.opts.Retry.retryable(a.Class) {
+			break
+		}
+		if !sleep(ctx, r.opts.Retry.backoff(a.Retries)) {
+			break
+		}
+		a.Retries++
+	}
+
+	a.Duration = time.Since(a.Start)
+	return a
+}
+
+// try runs the operation once with the runner's timeout. An empty op runs
+// the runner's clone operation.
+func (r *Runner) try(ctx context.Context, trace *git.Trace, op git.Operation, repo string) error {
+	ctx = git.WithTrace(ctx, trace)
+	if r.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.opts.Timeout)
+		defer cancel()
+	}
+	if op != "" {
+		return r.operation.(WorkloadOperation).Run(ctx, op, repo)
+	}
+	return r.operation.Execute(ctx, repo)
+}
+
+// report records a completed attempt and hands it to the consumers
+func (r *Runner) report(ctx context.Context, a Attempt) {
+	if a.Err != nil && ctx.Err() != nil {
+		// Most likely cut short by ctx rather than a real failure
+		return
+	}
+	r.mu.Lock()
+	if a.Err == nil {
+		r.success++
+	} else {
+		r.fail++
+	}
+	r.retries.Add(a)
+	r.backends.Add(a.Backend, a)
+	r.operations.Add(string(a.Op), a)
+	if a.Family != git.IPAny {
+		r.families.Add(a.Family.String(), a)
+	}
+	for _, w := range r.windows {
+		w.Add(a)
+	}
+	r.mu.Unlock()
+	r.achieved.add(time.Now())
+
+	r.logAttempt(a)
+	if r.opts.OnResult != nil {
+		r.deliver.Lock()
+		r.opts.OnResult(a)
+		r.deliver.Unlock()
+	}
+	if r.opts.Results != nil {
+		select {
+		case r.opts.Results <- a:
+		case <-ctx.Done():
+		}
+	}
+}
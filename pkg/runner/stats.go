@@ -0,0 +1,77 @@
+package runner
+
+import "time"
+
+// DefaultWindows are the spans recent stats are kept for unless
+// Options.Windows says otherwise
+var DefaultWindows = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+// Stats is a snapshot of a Runner's results so far
+type Stats struct {
+	// Elapsed is the time since Run was called
+	Elapsed  time.Duration
+	Success  int
+	Fail     int
+	InFlight int
+	// Skipped counts load profile starts skipped as MaxInFlight attempts
+	// were running
+	Skipped int
+	// TargetRate is the load profile's current rate and AchievedRate the
+	// attempts completed per second over the last 10 seconds
+	TargetRate   float64
+	AchievedRate float64
+	Retries      RetryStats
+	// Backends, Families and Operations break the results down by server
+	// address, address family and workload operation
+	Backends   *BreakdownStats
+	Families   *BreakdownStats
+	Operations *BreakdownStats
+	// Windows summarise the attempts that completed within each of the
+	// runner's windows, in the order of Options.Windows
+	Windows []WindowStats
+}
+
+// Attempts returns the number of completed attempts
+func (s Stats) Attempts() int {
+	return s.Success + s.Fail
+}
+
+// SuccessRate returns the percentage of completed attempts that succeeded
+func (s Stats) SuccessRate() float64 {
+	return percent(s.Success, s.Attempts())
+}
+
+// Throughput returns the attempts completed per second since Run was called
+func (s Stats) Throughput() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Attempts()) / s.Elapsed.Seconds()
+}
+
+// Snapshot returns the stats collected so far. It is safe to call while the
+// runner is running and the result is not affected by later attempts.
+func (r *Runner) Snapshot() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	s := Stats{
+		Success:      r.success,
+		Fail:         r.fail,
+		InFlight:     r.InFlight(),
+		Skipped:      r.Skipped(),
+		TargetRate:   r.TargetRate(),
+		AchievedRate: r.achieved.rate(now),
+		Retries:      *r.retries,
+		Backends:     r.backends.clone(),
+		Families:     r.families.clone(),
+		Operations:   r.operations.clone(),
+	}
+	for _, w := range r.windows {
+		s.Windows = append(s.Windows, w.Stats(now))
+	}
+	if !r.start.IsZero() {
+		s.Elapsed = time.Since(r.start)
+	}
+	return s
+}
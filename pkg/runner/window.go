@@ -0,0 +1,125 @@
+package runner
+
+import (
+	"slices"
+	"time"
+)
+
+// percentileRefresh is how often a Window re-sorts its samples for the
+// percentiles, which are otherwise reused between calls to Stats
+const percentileRefresh = time.Second
+
+// Window keeps the attempts that completed within a sliding time span. It is
+// not safe for concurrent use.
+type Window struct {
+	span    time.Duration
+	samples []sample
+	fail    int
+	// sorted, percentiles and computed cache the last percentiles, which
+	// are stale once samples change
+	sorted      []time.Duration
+	percentiles [3]time.Duration
+	computed    time.Time
+	stale       bool
+}
+
+type sample struct {
+	at       time.Time
+	duration time.Duration
+	failed   bool
+}
+
+// NewWindow creates a Window covering the given span
+func NewWindow(span time.Duration) *Window {
+	return &Window{span: span}
+}
+
+// Add records a completed attempt, dropping any that are now older than the
+// span
+func (w *Window) Add(a Attempt) {
+	at := a.Start.Add(a.Duration)
+	w.prune(at)
+	w.samples = append(w.samples, sample{at: at, duration: a.Duration, failed: a.Err != nil})
+	if a.Err != nil {
+		w.fail++
+	}
+	w.stale = true
+}
+
+// prune drops attempts that completed more than the span before now
+func (w *Window) prune(now time.Time) {
+	cutoff := now.Add(-w.span)
+	i := 0
+	for i < len(w.samples) && w.samples[i].at.Before(cutoff) {
+		if w.samples[i].failed {
+			w.fail--
+		}
+		i++
+	}
+	if i > 0 {
+		w.samples = w.samples[i:]
+		w.stale = true
+	}
+}
+
+// WindowStats summarises the attempts in a window
+type WindowStats struct {
+	Span    time.Duration
+	Success int
+	Fail    int
+	// Throughput is attempts completed per second over the span
+	Throughput float64
+	P50        time.Duration
+	P90        time.Duration
+	P99        time.Duration
+}
+
+// Attempts returns the number of attempts in the window
+func (s WindowStats) Attempts() int {
+	return s.Success + s.Fail
+}
+
+// SuccessRate returns the percentage of attempts in the window that succeeded
+func (s WindowStats) SuccessRate() float64 {
+	return percent(s.Success, s.Attempts())
+}
+
+// FailureRate returns the percentage of attempts in the window that failed
+func (s WindowStats) FailureRate() float64 {
+	return percent(s.Fail, s.Attempts())
+}
+
+// Stats drops attempts older than the span and summarises the rest. The
+// counts are exact, but the percentiles are only recomputed once a second
+// so calling Stats on every frame doesn't sort the whole window each time.
+func (w *Window) Stats(now time.Time) WindowStats {
+	w.prune(now)
+	if w.stale && (len(w.samples) == 0 || now.Sub(w.computed) >= percentileRefresh || now.Before(w.computed)) {
+		w.sorted = w.sorted[:0]
+		for _, smp := range w.samples {
+			w.sorted = append(w.sorted, smp.duration)
+		}
+		slices.Sort(w.sorted)
+		w.percentiles = [3]time.Duration{Percentile(w.sorted, 50), Percentile(w.sorted, 90), Percentile(w.sorted, 99)}
+		w.computed = now
+		w.stale = false
+	}
+	return WindowStats{
+		Span:       w.span,
+		Success:    len(w.samples) - w.fail,
+		Fail:       w.fail,
+		Throughput: float64(len(w.samples)) / w.span.Seconds(),
+		P50:        w.percentiles[0],
+		P90:        w.percentiles[1],
+		P99:        w.percentiles[2],
+	}
+}
+
+// Percentile returns the nearest-rank percentile p of sorted durations
+func Percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
@@ -0,0 +1,218 @@
+// Package statsd sends metrics on every attempt to a StatsD or DogStatsD
+// agent over UDP.
+package statsd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kloudyuk/gitter/pkg/git"
+	"github.com/kloudyuk/gitter/pkg/runner"
+)
+
+// Formats metrics can be sent in
+const (
+	// FormatDogStatsD tags metrics the way DogStatsD and Telegraf expect
+	FormatDogStatsD = "dogstatsd"
+	// FormatStatsD sends plain StatsD, which has no tags, putting the
+	// operation, outcome and error class in the metric name instead. The
+	// target isn't included, so runs against different targets need their
+	// own prefix.
+	FormatStatsD = "statsd"
+)
+
+// Formats lists the supported formats
+var Formats = []string{FormatDogStatsD, FormatStatsD}
+
+// DefaultPrefix is put in front of every metric name by default
+const DefaultPrefix = "gitter."
+
+// Options configures where metrics are sent and how they are named
+type Options struct {
+	// Addr is the agent's host:port
+	Addr string
+	// Format is FormatDogStatsD or FormatStatsD, defaulting to DogStatsD
+	Format string
+	// Prefix is put in front of every metric name
+	Prefix string
+	// Target and Operation tag attempts that don't name their own
+	// repository or operation
+	Target    string
+	Operation string
+	// Tags are added to every metric, as key:value. Plain StatsD has no
+	// tags, so they are only allowed with FormatDogStatsD.
+	Tags []string
+}
+
+// Sink sends a counter and timer for every attempt, and a gauge of the
+// attempts in flight
+type Sink struct {
+	opts Options
+	conn net.Conn
+	tags []string
+
+	mu sync.Mutex
+	// inFlight counts attempts in progress for each gauge, keyed by its
+	// name and, for DogStatsD, its tags
+	inFlight map[string]int
+	err      error
+	closed   bool
+}
+
+// New creates a Sink sending to the agent at opts.Addr. Along with
+// opts.Tags, metrics are tagged with the target, operation and host.
+func New(opts Options) (*Sink, error) {
+	if opts.Format == "" {
+		opts.Format = FormatDogStatsD
+	}
+	if !slices.Contains(Formats, opts.Format) {
+		return nil, fmt.Errorf("unknown StatsD format '%s', must be one of %s", opts.Format, strings.Join(Formats, ", "))
+	}
+	if strings.ContainsAny(opts.Prefix, ":|@#\n") {
+		return nil, fmt.Errorf("StatsD prefix must not contain ':', '|', '@' or '#', got '%s'", opts.Prefix)
+	}
+	if opts.Format == FormatStatsD && len(opts.Tags) > 0 {
+		return nil, errors.New("StatsD tags need the dogstatsd format")
+	}
+	for _, tag := range opts.Tags {
+		if tag == "" || strings.ContainsAny(tag, ",|#\n") {
+			return nil, fmt.Errorf("StatsD tags must be key:value without ',', '|' or '#', got '%s'", tag)
+		}
+	}
+	conn, err := net.Dial("udp", opts.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to StatsD agent: %w", err)
+	}
+
+	tags := slices.Clone(opts.Tags)
+	if host, err := os.Hostname(); err == nil {
+		tags = append(tags, tag("host", host))
+	}
+	return &Sink{opts: opts, conn: conn, tags: tags, inFlight: make(map[string]int)}, nil
+}
+
+// Instrument counts a as in flight until the returned function is called
+// with the completed attempt, which sends its metrics. It has the signature
+// of runner.Options.Instrument.
+func (s *Sink) Instrument(ctx context.Context, a runner.Attempt) (context.Context, func(runner.Attempt)) {
+	s.gauge(a, 1)
+	return ctx, func(a runner.Attempt) {
+		s.gauge(a, -1)
+		// Like the runner's stats, leave out attempts cut short by the run
+		// stopping
+		if a.Err != nil && ctx.Err() != nil {
+			return
+		}
+		s.record(a)
+	}
+}
+
+// record sends the attempts counter and duration timer for a
+func (s *Sink) record(a runner.Attempt) {
+	tags := s.attemptTags(a)
+	name, timer := "attempts", "attempt.duration"
+	if a.Err == nil {
+		tags = append(tags, tag("outcome", "ok"))
+		if s.opts.Format == FormatStatsD {
+			name, timer = "attempts.ok", "attempt.duration.ok"
+		}
+	} else {
+		class := string(a.Class)
+		if class == "" {
+			class = string(git.ClassOther)
+		}
+		tags = append(tags, tag("outcome", "fail"), tag("class", class))
+		if s.opts.Format == FormatStatsD {
+			name, timer = "attempts.fail."+class, "attempt.duration.fail"
+		}
+	}
+	ms := strconv.FormatFloat(a.Duration.Seconds()*1000, 'f', 3, 64)
+	s.send(s.line(name, "1", "c", tags), s.line(timer, ms, "ms", tags))
+}
+
+// gauge adjusts the attempts in flight counted by a's gauge by delta and
+// sends the new count. Plain StatsD gauges are per operation, so they count
+// every target together.
+func (s *Sink) gauge(a runner.Attempt, delta int) {
+	tags := s.attemptTags(a)
+	name := "attempts.in_flight"
+	if op := s.operation(a); s.opts.Format == FormatStatsD && op != "" {
+		name += "." + op
+	}
+	key := name
+	if s.opts.Format == FormatDogStatsD {
+		key += "|" + strings.Join(tags, ",")
+	}
+	// Hold the lock while sending so the agent sees the counts in order
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inFlight[key] += delta
+	s.write(s.line(name, strconv.Itoa(s.inFlight[key]), "g", tags))
+}
+
+// attemptTags returns the target and operation tags for a
+func (s *Sink) attemptTags(a runner.Attempt) []string {
+	target := s.opts.Target
+	if a.Repo != "" {
+		target = git.RedactURL(a.Repo)
+	}
+	return []string{tag("target", target), tag("operation", s.operation(a))}
+}
+
+// operation returns the operation a ran
+func (s *Sink) operation(a runner.Attempt) string {
+	if a.Op != "" {
+		return string(a.Op)
+	}
+	return s.opts.Operation
+}
+
+// line formats a metric, adding the sink's own tags to tags
+func (s *Sink) line(name, value, kind string, tags []string) string {
+	l := s.opts.Prefix + name + ":" + value + "|" + kind
+	if s.opts.Format == FormatDogStatsD {
+		l += "|#" + strings.Join(append(slices.Clip(tags), s.tags...), ",")
+	}
+	return l
+}
+
+// send writes lines to the agent in a single packet
+func (s *Sink) send(lines ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.write(lines...)
+}
+
+// write sends lines with s.mu held, keeping the first error for Close
+func (s *Sink) write(lines ...string) {
+	if s.closed {
+		return
+	}
+	if _, err := s.conn.Write([]byte(strings.Join(lines, "\n"))); err != nil && s.err == nil {
+		s.err = fmt.Errorf("sending StatsD metrics: %w", err)
+	}
+}
+
+// Close stops sending metrics, returning the first error sending them
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return s.err
+	}
+	s.closed = true
+	s.err = errors.Join(s.err, s.conn.Close())
+	return s.err
+}
+
+// tag formats a DogStatsD tag, replacing characters the protocol reserves
+func tag(key, value string) string {
+	return key + ":" + strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_").Replace(value)
+}
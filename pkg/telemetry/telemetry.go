@@ -0,0 +1,271 @@
+// Package telemetry sends a trace span for every attempt, with child spans
+// for the phases of the operation, and metrics on the results to an
+// OpenTelemetry collector over OTLP.
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/kloudyuk/gitter/pkg/git"
+	"github.com/kloudyuk/gitter/pkg/runner"
+)
+
+// Protocols OTLP can be sent over
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http"
+)
+
+// Protocols lists the supported protocols
+var Protocols = []string{ProtocolGRPC, ProtocolHTTP}
+
+// DefaultMetricInterval is how often metrics are sent by default
+const DefaultMetricInterval = 10 * time.Second
+
+// closeTimeout bounds sending the last spans and metrics on Close
+const closeTimeout = 5 * time.Second
+
+// Attribute keys set on spans and metrics, alongside the semantic
+// conventions' error.type and network.peer.address
+const (
+	TargetKey    = attribute.Key("gitter.target")
+	OperationKey = attribute.Key("gitter.operation")
+	AttemptKey   = attribute.Key("gitter.attempt")
+	RetriesKey   = attribute.Key("gitter.retries")
+	OutcomeKey   = attribute.Key("gitter.outcome")
+	PhaseKey     = attribute.Key("gitter.phase")
+)
+
+// Options configures where telemetry is sent and what it describes
+type Options struct {
+	// Endpoint is the collector's URL, such as http://localhost:4317.
+	// An http URL sends without TLS.
+	Endpoint string
+	// Protocol is ProtocolGRPC or ProtocolHTTP, defaulting to gRPC
+	Protocol string
+	// Target and Operation describe attempts that don't name their own
+	// repository or operation
+	Target    string
+	Operation string
+	// Version is reported as the service version
+	Version string
+	// MetricInterval is how often metrics are sent. Zero uses
+	// DefaultMetricInterval.
+	MetricInterval time.Duration
+}
+
+// Telemetry instruments attempts, sending them to a collector
+type Telemetry struct {
+	opts    Options
+	traces  *sdktrace.TracerProvider
+	metrics *sdkmetric.MeterProvider
+	tracer  trace.Tracer
+	// propagator writes the attempt's span into the headers of its git
+	// requests
+	propagator propagation.TextMapPropagator
+	attempts   metric.Int64Counter
+	duration   metric.Float64Histogram
+	phases     metric.Float64Histogram
+	inFlight   metric.Int64UpDownCounter
+}
+
+// New creates Telemetry sending to the collector at opts.Endpoint. Other
+// settings, such as headers, are taken from the standard
+// OTEL_EXPORTER_OTLP_* environment variables.
+func New(ctx context.Context, opts Options) (*Telemetry, error) {
+	u, err := ParseEndpoint(opts.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Protocol == "" {
+		opts.Protocol = ProtocolGRPC
+	}
+
+	var spans sdktrace.SpanExporter
+	var metrics sdkmetric.Exporter
+	switch opts.Protocol {
+	case ProtocolGRPC:
+		traceOpts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(u.Host)}
+		metricOpts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(u.Host)}
+		if u.Scheme == "http" {
+			traceOpts = append(traceOpts, otlptracegrpc.WithInsecure())
+			metricOpts = append(metricOpts, otlpmetricgrpc.WithInsecure())
+		}
+		if spans, err = otlptracegrpc.New(ctx, traceOpts...); err != nil {
+			return nil, fmt.Errorf("creating trace exporter: %w", err)
+		}
+		metrics, err = otlpmetricgrpc.New(ctx, metricOpts...)
+	case ProtocolHTTP:
+		path := strings.TrimSuffix(u.Path, "/")
+		traceOpts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(u.Host), otlptracehttp.WithURLPath(path + "/v1/traces")}
+		metricOpts := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpoint(u.Host), otlpmetrichttp.WithURLPath(path + "/v1/metrics")}
+		if u.Scheme == "http" {
+			traceOpts = append(traceOpts, otlptracehttp.WithInsecure())
+			metricOpts = append(metricOpts, otlpmetrichttp.WithInsecure())
+		}
+		if spans, err = otlptracehttp.New(ctx, traceOpts...); err != nil {
+			return nil, fmt.Errorf("creating trace exporter: %w", err)
+		}
+		metrics, err = otlpmetrichttp.New(ctx, metricOpts...)
+	default:
+		return nil, fmt.Errorf("unknown OTLP protocol '%s', must be one of %s", opts.Protocol, strings.Join(Protocols, ", "))
+	}
+	if err != nil {
+		_ = spans.Shutdown(ctx)
+		return nil, fmt.Errorf("creating metric exporter: %w", err)
+	}
+
+	interval := opts.MetricInterval
+	if interval == 0 {
+		interval = DefaultMetricInterval
+	}
+	return newTelemetry(ctx, opts, sdktrace.WithBatcher(spans), sdkmetric.NewPeriodicReader(metrics, sdkmetric.WithInterval(interval)))
+}
+
+// newTelemetry creates Telemetry sending spans through processor and
+// metrics through reader
+func newTelemetry(ctx context.Context, opts Options, processor sdktrace.TracerProviderOption, reader sdkmetric.Reader) (*Telemetry, error) {
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithAttributes(semconv.ServiceName("gitter"), semconv.ServiceVersion(opts.Version)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("describing telemetry resource: %w", err)
+	}
+
+	t := &Telemetry{
+		opts:       opts,
+		traces:     sdktrace.NewTracerProvider(processor, sdktrace.WithResource(res)),
+		metrics:    sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithResource(res)),
+		propagator: propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+	}
+	t.tracer = t.traces.Tracer("github.com/kloudyuk/gitter")
+	meter := t.metrics.Meter("github.com/kloudyuk/gitter")
+	t.attempts, err = meter.Int64Counter("gitter.attempts",
+		metric.WithDescription("Completed attempts"), metric.WithUnit("{attempt}"))
+	if err == nil {
+		t.duration, err = meter.Float64Histogram("gitter.attempt.duration",
+			metric.WithDescription("Duration of attempts, including retries"), metric.WithUnit("s"))
+	}
+	if err == nil {
+		t.phases, err = meter.Float64Histogram("gitter.attempt.phase.duration",
+			metric.WithDescription("Duration of each phase of an attempt's final try"), metric.WithUnit("s"))
+	}
+	if err == nil {
+		t.inFlight, err = meter.Int64UpDownCounter("gitter.attempts.in_flight",
+			metric.WithDescription("Attempts in progress"), metric.WithUnit("{attempt}"))
+	}
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("creating metrics: %w", err), t.Close())
+	}
+	return t, nil
+}
+
+// ParseEndpoint checks endpoint is an http or https URL
+func ParseEndpoint(endpoint string) (*url.URL, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("OTLP endpoint must be an http or https URL such as http://localhost:4317, got '%s'", endpoint)
+	}
+	return u, nil
+}
+
+// Instrument starts a span for a as it begins, returning the context to run
+// it with and the function ending it. The context carries the span's W3C
+// trace context as headers for git requests, so spans recorded by the
+// server join the attempt's trace. It has the signature of
+// runner.Options.Instrument.
+func (t *Telemetry) Instrument(ctx context.Context, a runner.Attempt) (context.Context, func(runner.Attempt)) {
+	attrs := t.attrs(a)
+	t.inFlight.Add(ctx, 1, metric.WithAttributes(attrs...))
+	parent := ctx
+	ctx, span := t.tracer.Start(ctx, t.operation(a),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(a.Start),
+		trace.WithAttributes(append(attrs, AttemptKey.Int(a.ID))...),
+	)
+	header := http.Header{}
+	t.propagator.Inject(ctx, propagation.HeaderCarrier(header))
+	return git.WithHeader(ctx, header), func(a runner.Attempt) {
+		end := a.Start.Add(a.Duration)
+		t.inFlight.Add(ctx, -1, metric.WithAttributes(attrs...))
+		for _, s := range a.Spans {
+			_, child := t.tracer.Start(ctx, string(s.Phase), trace.WithTimestamp(s.Start), trace.WithAttributes(PhaseKey.String(string(s.Phase))))
+			child.End(trace.WithTimestamp(s.Start.Add(s.Duration)))
+			t.phases.Record(ctx, s.Duration.Seconds(), metric.WithAttributes(append(attrs, PhaseKey.String(string(s.Phase)))...))
+		}
+
+		span.SetAttributes(RetriesKey.Int(a.Retries))
+		if a.Backend != "" {
+			span.SetAttributes(semconv.NetworkPeerAddress(a.Backend))
+		}
+		if a.Err != nil {
+			span.SetAttributes(semconv.ErrorTypeKey.String(string(a.Class)))
+			span.SetStatus(codes.Error, a.Err.Error())
+		}
+		span.End(trace.WithTimestamp(end))
+
+		// Like the runner's stats, leave out attempts cut short by the run
+		// stopping
+		if a.Err != nil && parent.Err() != nil {
+			return
+		}
+		outcome := OutcomeKey.String("ok")
+		if a.Err != nil {
+			outcome = OutcomeKey.String("fail")
+		}
+		measured := append(slices.Clip(attrs), outcome)
+		if a.Err != nil {
+			measured = append(measured, semconv.ErrorTypeKey.String(string(a.Class)))
+		}
+		t.attempts.Add(ctx, 1, metric.WithAttributes(measured...))
+		t.duration.Record(ctx, a.Duration.Seconds(), metric.WithAttributes(measured...))
+	}
+}
+
+// attrs returns the target and operation of a
+func (t *Telemetry) attrs(a runner.Attempt) []attribute.KeyValue {
+	target := t.opts.Target
+	if a.Repo != "" {
+		target = git.RedactURL(a.Repo)
+	}
+	return []attribute.KeyValue{TargetKey.String(target), OperationKey.String(t.operation(a))}
+}
+
+// operation returns the operation a ran
+func (t *Telemetry) operation(a runner.Attempt) string {
+	if a.Op != "" {
+		return string(a.Op)
+	}
+	return t.opts.Operation
+}
+
+// Close sends any spans and metrics not yet sent and stops sending
+func (t *Telemetry) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	return errors.Join(t.traces.Shutdown(ctx), t.metrics.Shutdown(ctx))
+}
@@ -0,0 +1,69 @@
+package ui
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/kloudyuk/gitter/pkg/alert"
+	"github.com/kloudyuk/gitter/pkg/hooks"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// levelColours are the colours used for each alert level
+var levelColours = map[alert.Level]lipgloss.Color{
+	alert.OK:   lipgloss.Color("#00FF00"),
+	alert.Warn: lipgloss.Color("#FFAA00"),
+	alert.Crit: lipgloss.Color("#FF0000"),
+}
+
+// reportAlerts logs alert rules that changed level and sends them to the
+// hooks as threshold events
+func reportAlerts(changes []alert.State, target string, log *slog.Logger, dispatcher *hooks.Dispatcher) {
+	for _, s := range changes {
+		if log != nil {
+			log.Warn("alert level changed",
+				"target", target,
+				"rule", s.Rule.String(),
+				"level", s.Level.String(),
+				"value", s.Rule.Format(s.Value),
+			)
+		}
+		if dispatcher != nil {
+			dispatcher.Fire(hooks.Event{
+				Kind:    hooks.ThresholdBreach,
+				Time:    s.Since,
+				Target:  target,
+				Message: s.Reason(),
+				Since:   s.Since,
+				Rule:    s.Rule.String(),
+				Level:   s.Level.String(),
+			})
+		}
+	}
+}
+
+// alertBannerView explains which rules are above OK and since when
+func (m model) alertBannerView() string {
+	if m.alerts == nil {
+		return ""
+	}
+	var lines []string
+	for _, s := range m.alerts.States() {
+		if s.Level <= alert.OK {
+			continue
+		}
+		style := lipgloss.NewStyle().Foreground(levelColours[s.Level]).Bold(true)
+		lines = append(lines, style.Render(fmt.Sprintf("%s: %s since %s",
+			strings.ToUpper(s.Level.String()),
+			s.Reason(),
+			s.Since.Format(time.TimeOnly),
+		)))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
@@ -0,0 +1,75 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kloudyuk/gitter/pkg/git"
+	"github.com/kloudyuk/gitter/pkg/runner"
+)
+
+// DefaultCertExpiryWarning is how close to expiry a server certificate must be
+// before gitter warns about it
+const DefaultCertExpiryWarning = 14 * 24 * time.Hour
+
+// certMonitor tracks the certificate presented by the server across attempts
+type certMonitor struct {
+	warnBefore time.Duration
+	current    *git.CertInfo
+	previous   *git.CertInfo
+	changedAt  time.Time
+	changes    int
+	// warnedExpiry is the fingerprint of the last certificate logged as
+	// expiring, so each certificate is only logged once
+	warnedExpiry string
+}
+
+func newCertMonitor(warnBefore time.Duration) *certMonitor {
+	return &certMonitor{warnBefore: warnBefore}
+}
+
+// observe records the certificate seen by an attempt and reports whether it
+// differs from the one seen before
+func (c *certMonitor) observe(a runner.Attempt) bool {
+	if a.Cert == nil {
+		return false
+	}
+	changed := c.current != nil && c.current.Fingerprint != a.Cert.Fingerprint
+	if changed {
+		c.previous = c.current
+		c.changedAt = a.Start
+		c.changes++
+	}
+	c.current = a.Cert
+	return changed
+}
+
+// expiring reports whether the current certificate expires within the
+// warning window
+func (c *certMonitor) expiring(now time.Time) bool {
+	return c.current != nil && c.warnBefore > 0 && c.current.NotAfter.Sub(now) < c.warnBefore
+}
+
+// warnings describes any problems with the certificates seen so far
+func (c *certMonitor) warnings(now time.Time) []string {
+	var warnings []string
+	if c.expiring(now) {
+		if left := c.current.NotAfter.Sub(now); left > 0 {
+			warnings = append(warnings, fmt.Sprintf("TLS certificate expires in %s (%s)", formatDays(left), c.current.NotAfter.Format(time.DateOnly)))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("TLS certificate expired %s ago (%s)", formatDays(-left), c.current.NotAfter.Format(time.DateOnly)))
+		}
+	}
+	if c.changes > 0 {
+		warnings = append(warnings, fmt.Sprintf("TLS certificate changed %d time(s), last at %s (was %s)", c.changes, c.changedAt.Format(time.TimeOnly), c.previous.Subject))
+	}
+	return warnings
+}
+
+// formatDays renders a duration in whole days, or hours when under a day
+func formatDays(d time.Duration) string {
+	if d < 24*time.Hour {
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+}
@@ -0,0 +1,163 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kloudyuk/gitter/pkg/git"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// errorBrowser is a full-screen, scrollable view of every recorded error
+type errorBrowser struct {
+	open      bool
+	searching bool
+	search    textinput.Model
+	classIdx  int // 0 means all classes, otherwise an index into git.ErrorClasses+1
+	viewport  viewport.Model
+	styles    *Styles
+}
+
+func newErrorBrowser(styles *Styles) *errorBrowser {
+	search := textinput.New()
+	search.Prompt = "/"
+	search.Placeholder = "filter by text or class"
+	return &errorBrowser{
+		search:   search,
+		viewport: viewport.New(styles.width, 20),
+		styles:   styles,
+	}
+}
+
+// resize fits the browser to the terminal, leaving room for the header and footer
+func (b *errorBrowser) resize(width, height int) {
+	b.viewport.Width = width
+	b.viewport.Height = max(height-4, 1)
+}
+
+// class returns the class currently being filtered on, if any
+func (b *errorBrowser) class() git.ErrorClass {
+	if b.classIdx == 0 {
+		return git.ClassNone
+	}
+	return git.ErrorClasses[b.classIdx-1]
+}
+
+// filter returns the errors matching the current search text and class, newest first
+func (b *errorBrowser) filter(errs []errorInfo) []errorInfo {
+	text := strings.ToLower(strings.TrimSpace(b.search.Value()))
+	class := b.class()
+
+	var matched []errorInfo
+	for i := len(errs) - 1; i >= 0; i-- {
+		e := errs[i]
+		if class != git.ClassNone && e.class != class {
+			continue
+		}
+		if text != "" &&
+			!strings.Contains(strings.ToLower(e.err.Error()), text) &&
+			!strings.Contains(string(e.class), text) {
+			continue
+		}
+		matched = append(matched, e)
+	}
+	return matched
+}
+
+// refresh rebuilds the viewport content from the current errors
+func (b *errorBrowser) refresh(errs []errorInfo) {
+	matched := b.filter(errs)
+	errStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF6666"))
+	wrap := lipgloss.NewStyle().Width(max(b.viewport.Width-2, 10))
+
+	lines := make([]string, 0, len(matched))
+	for _, e := range matched {
+		header := fmt.Sprintf("#%d  %s  %s  [%s]",
+			e.attempt,
+			e.timestamp.Format("2006-01-02 15:04:05.000"),
+			e.duration.Round(time.Millisecond),
+			e.class,
+		)
+		if e.handshake > 0 {
+			header += fmt.Sprintf("  handshake %s", e.handshake.Round(time.Millisecond))
+		}
+		if e.op != "" {
+			header += "  op " + string(e.op)
+		}
+		if e.backend != "" {
+			header += "  backend " + e.backend
+		}
+		lines = append(lines, header, wrap.Render(errStyle.Render(e.err.Error())), "")
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "No matching errors")
+	}
+	b.viewport.SetContent(strings.Join(lines, "\n"))
+}
+
+// Update handles input while the browser is open
+func (b *errorBrowser) Update(msg tea.KeyMsg, errs []errorInfo) tea.Cmd {
+	var cmd tea.Cmd
+	if b.searching {
+		switch msg.String() {
+		case "enter":
+			b.searching = false
+			b.search.Blur()
+		case "esc":
+			b.searching = false
+			b.search.Blur()
+			b.search.Reset()
+		default:
+			b.search, cmd = b.search.Update(msg)
+		}
+		b.refresh(errs)
+		b.viewport.GotoTop()
+		return cmd
+	}
+
+	switch msg.String() {
+	case "esc", "q", "e":
+		b.open = false
+		return nil
+	case "/":
+		b.searching = true
+		return b.search.Focus()
+	case "c":
+		b.classIdx = (b.classIdx + 1) % (len(git.ErrorClasses) + 1)
+		b.refresh(errs)
+		b.viewport.GotoTop()
+		return nil
+	}
+	b.viewport, cmd = b.viewport.Update(msg)
+	return cmd
+}
+
+func (b *errorBrowser) View(errs []errorInfo) string {
+	class := "all"
+	if c := b.class(); c != git.ClassNone {
+		class = string(c)
+	}
+	header := fmt.Sprintf("%s  %d of %d shown  class: %s",
+		b.styles.SectionTitle("Error Log", "#BBBB00"),
+		len(b.filter(errs)),
+		len(errs),
+		class,
+	)
+
+	footer := "↑/↓ scroll • / search • c cycle class • esc close"
+	if b.searching || b.search.Value() != "" {
+		footer = b.search.View()
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		header,
+		"",
+		b.viewport.View(),
+		lipgloss.NewStyle().Faint(true).Render(footer),
+	)
+}
@@ -1,12 +1,61 @@
 package ui
 
 import (
+	"regexp"
+	"sort"
 	"time"
+
+	"github.com/kloudyuk/gitter/pkg/git"
+	"github.com/kloudyuk/gitter/pkg/runner"
 )
 
+// MaxLoggedErrors bounds the number of errors kept for the error browser so
+// that very long runs against a dead server don't grow without limit
+const MaxLoggedErrors = 10000
+
+// MaxErrorGroups bounds the number of distinct error groups. Errors that
+// don't match an existing group once the limit is reached are counted under
+// OtherErrorsGroup.
+const MaxErrorGroups = 100
+
+// OtherErrorsGroup is the key errors are grouped under once MaxErrorGroups
+// distinct groups have been seen
+const OtherErrorsGroup = "other errors"
+
+// normalizers strip the variable parts of error messages so that otherwise
+// identical errors group together. They are applied in order.
+var normalizers = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`\[[0-9a-fA-F:]*:[0-9a-fA-F:.]*\](:\d+)?`), "<ip>"},
+	{regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}(:\d+)?\b`), "<ip>"},
+	{regexp.MustCompile(`\b[0-9a-fA-F]{7,64}\b`), "<sha>"},
+	{regexp.MustCompile(`:\d{2,5}\b`), ":<port>"},
+}
+
+// normalizeError reduces an error message to its grouping key
+func normalizeError(msg string) string {
+	for _, n := range normalizers {
+		msg = n.re.ReplaceAllString(msg, n.repl)
+	}
+	return msg
+}
+
+// errorGroup aggregates errors sharing the same normalized message
+type errorGroup struct {
+	key       string
+	class     git.ErrorClass
+	count     int
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
 // ErrorStats tracks error statistics with configurable history
 type ErrorStats struct {
 	recentErrors []errorInfo
+	allErrors    []errorInfo
+	groups       map[string]*errorGroup
 	maxRecent    int
 	totalErrors  int
 }
@@ -15,6 +64,8 @@ type ErrorStats struct {
 func NewErrorStats(maxRecent int) *ErrorStats {
 	return &ErrorStats{
 		recentErrors: make([]errorInfo, 0),
+		allErrors:    make([]errorInfo, 0),
+		groups:       make(map[string]*errorGroup),
 		maxRecent:    maxRecent,
 		totalErrors:  0,
 	}
@@ -22,17 +73,53 @@ func NewErrorStats(maxRecent int) *ErrorStats {
 
 // AddError adds an error to the tracking system
 func (es *ErrorStats) AddError(err error, timestamp time.Time) {
-	es.totalErrors++
-	errorInfo := errorInfo{
+	es.add(errorInfo{
 		err:       err,
 		timestamp: timestamp,
-	}
-	es.recentErrors = append(es.recentErrors, errorInfo)
+		class:     git.Classify(err),
+	})
+}
+
+// AddAttempt adds a failed clone attempt to the tracking system
+func (es *ErrorStats) AddAttempt(a runner.Attempt) {
+	es.add(errorInfo{
+		err:       a.Err,
+		timestamp: a.Start,
+		attempt:   a.ID,
+		duration:  a.Duration,
+		handshake: a.Handshake,
+		class:     a.Class,
+		backend:   a.Backend,
+		op:        a.Op,
+	})
+}
+
+func (es *ErrorStats) add(info errorInfo) {
+	es.totalErrors++
+	es.recentErrors = append(es.recentErrors, info)
 
 	// Keep only the most recent errors
 	if len(es.recentErrors) > es.maxRecent {
 		es.recentErrors = es.recentErrors[1:]
 	}
+
+	es.allErrors = append(es.allErrors, info)
+	if len(es.allErrors) > MaxLoggedErrors {
+		es.allErrors = es.allErrors[1:]
+	}
+
+	key := normalizeError(info.err.Error())
+	class := info.class
+	if _, ok := es.groups[key]; !ok && len(es.groups) >= MaxErrorGroups {
+		key, class = OtherErrorsGroup, git.ClassOther
+	}
+	group, ok := es.groups[key]
+	if !ok {
+		group = &errorGroup{key: key, class: class, firstSeen: info.timestamp}
+		es.groups[key] = group
+	}
+	group.count++
+	group.lastSeen = info.timestamp
 }
 
 // GetRecentErrors returns the recent errors for display
@@ -40,6 +127,30 @@ func (es *ErrorStats) GetRecentErrors() []errorInfo {
 	return es.recentErrors
 }
 
+// GetAllErrors returns every stored error, oldest first
+func (es *ErrorStats) GetAllErrors() []errorInfo {
+	return es.allErrors
+}
+
+// GetTopGroups returns up to n error groups, most frequent first. Ties are
+// broken by the most recently seen group.
+func (es *ErrorStats) GetTopGroups(n int) []errorGroup {
+	groups := make([]errorGroup, 0, len(es.groups))
+	for _, g := range es.groups {
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].count != groups[j].count {
+			return groups[i].count > groups[j].count
+		}
+		return groups[i].lastSeen.After(groups[j].lastSeen)
+	})
+	if len(groups) > n {
+		groups = groups[:n]
+	}
+	return groups
+}
+
 // GetTotalErrors returns the total number of errors encountered
 func (es *ErrorStats) GetTotalErrors() int {
 	return es.totalErrors
@@ -0,0 +1,96 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kloudyuk/gitter/pkg/alert"
+	"github.com/kloudyuk/gitter/pkg/runner"
+)
+
+// RunHeadless runs cfg without the terminal UI, writing a line to w for
+// every attempt and a summary once ctx is done
+func RunHeadless(ctx context.Context, cfg Config, w io.Writer) error {
+	alerts := alert.NewEvaluator(cfg.Alerts)
+	target := cfg.target()
+	// mu serialises the alert evaluator and writes to w between attempts and
+	// the ticker
+	var mu sync.Mutex
+	report := func(changes []alert.State) {
+		for _, s := range changes {
+			_, _ = fmt.Fprintf(w, "ALERT %s: %s\n", strings.ToUpper(s.Level.String()), s.Reason())
+		}
+		reportAlerts(changes, target, cfg.Log, cfg.Hooks)
+	}
+	r, _, err := newRunner(cfg, nil, func(a runner.Attempt) {
+		mu.Lock()
+		defer mu.Unlock()
+		_, _ = fmt.Fprintln(w, formatAttempt(a))
+		report(alerts.Observe(a))
+	})
+	if err != nil {
+		return err
+	}
+
+	// Re-evaluate the alerts every second as the TUI does, so they clear
+	// once failures age out even when no attempts finish
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				mu.Lock()
+				report(alerts.Evaluate(now))
+				mu.Unlock()
+			}
+		}
+	}()
+	err = r.Run(ctx)
+	close(done)
+	<-stopped
+	if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	waitHooks(cfg)
+	s := r.Snapshot()
+	_, err = fmt.Fprintf(w, "%d attempts in %s: %d succeeded, %d failed (%.1f%%)\n",
+		s.Attempts(), s.Elapsed.Truncate(time.Second), s.Success, s.Fail, s.SuccessRate())
+	return err
+}
+
+// formatAttempt describes an attempt on one line
+func formatAttempt(a runner.Attempt) string {
+	fields := []string{
+		a.Start.Format(time.TimeOnly),
+		fmt.Sprintf("#%d", a.ID),
+	}
+	if a.Op != "" {
+		fields = append(fields, string(a.Op))
+	}
+	if a.Err == nil {
+		fields = append(fields, "ok")
+	} else {
+		fields = append(fields, "FAIL")
+	}
+	fields = append(fields, a.Duration.Round(time.Millisecond).String())
+	if a.Backend != "" {
+		fields = append(fields, a.Backend)
+	}
+	if a.Retries > 0 {
+		fields = append(fields, fmt.Sprintf("retries=%d", a.Retries))
+	}
+	if a.Err != nil {
+		fields = append(fields, fmt.Sprintf("%s: %v", a.Class, a.Err))
+	}
+	return strings.Join(fields, " ")
+}
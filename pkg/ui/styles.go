@@ -1,6 +1,10 @@
 package ui
 
-import "github.com/charmbracelet/lipgloss"
+import (
+	"github.com/kloudyuk/gitter/pkg/alert"
+
+	"github.com/charmbracelet/lipgloss"
+)
 
 // Centralized styling configuration
 type Styles struct {
@@ -39,11 +43,17 @@ func (s *Styles) Error() lipgloss.Style {
 	return lipgloss.NewStyle()
 }
 
-func (s *Styles) Result() lipgloss.Style {
-	return lipgloss.NewStyle().
+// Result styles the results area, coloured by the alert level unless no
+// alert rules are configured
+func (s *Styles) Result(level alert.Level) lipgloss.Style {
+	style := lipgloss.NewStyle().
 		Width(s.width - 2).
 		BorderStyle(lipgloss.NormalBorder()).
 		BorderTop(true)
+	if colour, ok := levelColours[level]; ok {
+		style = style.BorderForeground(colour)
+	}
+	return style
 }
 
 func (s *Styles) SectionTitle(text, color string) string {
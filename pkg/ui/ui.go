@@ -1,49 +1,144 @@
 package ui
 
 import (
+	"context"
 	"fmt"
-	"io"
-	"os"
+	"log/slog"
 	"runtime"
 	"time"
 
+	"github.com/kloudyuk/gitter/pkg/alert"
+	"github.com/kloudyuk/gitter/pkg/demo"
+	"github.com/kloudyuk/gitter/pkg/export"
+	"github.com/kloudyuk/gitter/pkg/git"
+	"github.com/kloudyuk/gitter/pkg/history"
+	"github.com/kloudyuk/gitter/pkg/hooks"
+	"github.com/kloudyuk/gitter/pkg/runner"
+	"github.com/kloudyuk/gitter/pkg/statsd"
+	"github.com/kloudyuk/gitter/pkg/telemetry"
+	"github.com/kloudyuk/gitter/pkg/workload"
+
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
-// errorInfo represents an error with its timestamp
+// errorInfo represents an error with its timestamp and the attempt that produced it
 type errorInfo struct {
 	err       error
 	timestamp time.Time
+	attempt   int
+	duration  time.Duration
+	handshake time.Duration
+	class     git.ErrorClass
+	backend   string
+	op        git.Operation
 }
 
 type memStatMsg struct{}
 
 type resultMsg struct {
-	err error
+	attempt runner.Attempt
 }
 
 type model struct {
-	settings    *appSettings
-	stats       *AppStats
-	errorStats  *ErrorStats
-	success     result
-	fail        result
-	resultC     chan error
-	cloneRunner *CloneRunner
-	styles      *Styles
+	settings   *appSettings
+	stats      *AppStats
+	errorStats *ErrorStats
+	success    result
+	fail       result
+	resultC    chan runner.Attempt
+	runner     *runner.Runner
+	// runCtx stops the runner when the program exits, and runDone is closed
+	// once it has returned
+	runCtx  context.Context
+	runDone chan struct{}
+	styles  *Styles
+	browser *errorBrowser
+	certs   *certMonitor
+	alerts  *alert.Evaluator
 }
 
 type appSettings struct {
-	t            *time.Ticker
-	repo         string
+	t    *time.Ticker
+	repo string
+	// target is repo with any credentials hidden, for logs and hooks
+	target       string
 	timeout      time.Duration
 	interval     time.Duration
-	log          io.Writer
+	log          *slog.Logger
 	demoMode     bool
 	width        int
 	errorHistory int
+	seed         uint64
+	simulator    *demo.Simulator
+	dualStack    bool
+	retry        runner.RetryPolicy
+	profile      runner.LoadProfile
+	workload     *workload.Workload
+	operation    string
+	hooks        *hooks.Dispatcher
+	// pinned is set when backends are chosen explicitly, so the breakdown is
+	// shown even while only one has been seen
+	pinned bool
+}
+
+// Config holds the settings for a gitter run
+type Config struct {
+	Repo         string
+	Interval     time.Duration
+	Timeout      time.Duration
+	Width        int
+	DemoMode     bool
+	ErrorHistory int
+	// Seed and Scenario drive the simulated server in demo mode. A nil
+	// Scenario uses demo.DefaultScenario.
+	Seed     uint64
+	Scenario *demo.Scenario
+	// Operation names the registered operation to run, defaulting to
+	// runner.DefaultOperation. DemoMode always uses "demo".
+	Operation string
+	// Operations is the registry Operation is looked up in. Nil uses
+	// runner.DefaultRegistry.
+	Operations *runner.Registry
+	// Git configures real clones
+	Git git.Options
+	// LoadProfile, when set, replaces Interval with a varying rate of
+	// clone starts
+	LoadProfile runner.LoadProfile
+	// MaxInFlight bounds the attempts a load profile runs at once, zero
+	// for no limit
+	MaxInFlight int
+	// Workload, when set, picks a mix of operations and repositories
+	// instead of cloning Repo every time
+	Workload *workload.Workload
+	// Retry is applied to every attempt. The zero value never retries.
+	Retry runner.RetryPolicy
+	// DualStack alternates attempts between IPv4 and IPv6, overriding
+	// Git.IPFamily
+	DualStack bool
+	// CertExpiryWarning is how close to expiry the server certificate must
+	// be before gitter warns. Zero disables the warning.
+	CertExpiryWarning time.Duration
+	// Alerts are thresholds the run is judged against
+	Alerts []alert.Rule
+	// Hooks, if set, is told about every attempt and alert level change so
+	// it can notify external systems
+	Hooks *hooks.Dispatcher
+	// History, if set, records every attempt so the run can be looked at
+	// again later
+	History *history.Recorder
+	// Exports are sent every attempt and closed by the caller once the run
+	// is over
+	Exports []export.Exporter
+	// Telemetry, if set, traces every attempt and sends metrics to an
+	// OpenTelemetry collector. The caller closes it once the run is over.
+	Telemetry *telemetry.Telemetry
+	// StatsD, if set, sends metrics on every attempt to a StatsD agent. The
+	// caller closes it once the run is over.
+	StatsD *statsd.Sink
+	// Log receives a record for every attempt. Nil disables logging.
+	Log *slog.Logger
 }
 
 type result struct {
@@ -58,14 +153,18 @@ func updateMemoryStats(c <-chan time.Time) tea.Cmd {
 	}
 }
 
-func waitForResults(resultC <-chan error) tea.Cmd {
+func waitForResults(resultC <-chan runner.Attempt) tea.Cmd {
 	return func() tea.Msg {
 		return resultMsg{<-resultC}
 	}
 }
 
 func (m model) Init() tea.Cmd {
-	cloneCmd := m.cloneRunner.Start()
+	cloneCmd := func() tea.Msg {
+		defer close(m.runDone)
+		_ = m.runner.Run(m.runCtx)
+		return nil
+	}
 
 	return tea.Batch(
 		m.success.spinner.Tick,
@@ -82,6 +181,19 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if msg.String() == "ctrl+c" {
 			return m, tea.Quit
 		}
+		if m.browser.open {
+			cmd := m.browser.Update(msg, m.errorStats.GetAllErrors())
+			return m, cmd
+		}
+		if msg.String() == "e" {
+			m.browser.open = true
+			m.browser.refresh(m.errorStats.GetAllErrors())
+			m.browser.viewport.GotoTop()
+		}
+		return m, nil
+	case tea.WindowSizeMsg:
+		m.browser.resize(msg.Width, msg.Height)
+		m.browser.refresh(m.errorStats.GetAllErrors())
 		return m, nil
 	case memStatMsg:
 		runtime.ReadMemStats(m.stats.memStats)
@@ -90,6 +202,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		// Update stats using the new method
 		m.stats.UpdateStats(goroutines, memory)
+		if m.alerts != nil {
+			reportAlerts(m.alerts.Evaluate(time.Now()), m.settings.target, m.settings.log, m.settings.hooks)
+		}
 
 		return m, updateMemoryStats(m.stats.t.C)
 	case spinner.TickMsg:
@@ -105,16 +220,17 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 	case resultMsg:
-		if msg.err == nil {
+		if msg.attempt.Err == nil {
 			m.success.count++
 		} else {
 			m.fail.count++
-			m.errorStats.AddError(msg.err, time.Now())
-			// Only log to file in real mode (not demo mode)
-			if m.settings.log != nil {
-				_, _ = m.settings.log.Write([]byte(msg.err.Error() + "\n"))
+			m.errorStats.AddAttempt(msg.attempt)
+			if m.browser.open {
+				m.browser.refresh(m.errorStats.GetAllErrors())
 			}
 		}
+		m.checkCert(msg.attempt)
+		reportAlerts(m.alerts.Observe(msg.attempt), m.settings.target, m.settings.log, m.settings.hooks)
 		return m, waitForResults(m.resultC)
 	default:
 		return m, nil
@@ -122,19 +238,28 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m model) View() string {
+	if m.browser.open {
+		return m.browser.View(m.errorStats.GetAllErrors())
+	}
+	stats := m.runner.Snapshot()
 	return m.styles.Main().Render(
 		lipgloss.JoinVertical(lipgloss.Top,
 			m.styles.Title().Render("Gitter"),
 			m.styles.Config().Render(m.configView()),
-			m.styles.Stats().Render(m.statsView()),
+			m.styles.Stats().Render(m.statsView(stats)),
+			m.certWarningView(),
+			m.familiesView(stats),
+			m.operationsView(stats),
+			m.backendsView(stats),
 			m.styles.Error().Render(m.errView()),
-			m.styles.Result().Render(m.resultsView()),
+			m.alertBannerView(),
+			m.styles.Result(m.alerts.Level()).Render(m.resultsView(stats)),
 		),
 	)
 }
 
 func (m model) configView() string {
-	return fmt.Sprintf(`%s
+	view := fmt.Sprintf(`%s
 Repo         : %s
 Interval     : %s
 Timeout      : %s
@@ -145,11 +270,28 @@ Error History: %d`,
 		m.settings.timeout,
 		m.settings.errorHistory,
 	)
+	if m.settings.operation != "" && m.settings.operation != runner.DefaultOperation && !m.settings.demoMode {
+		view += fmt.Sprintf(`
+Operation    : %s`, m.settings.operation)
+	}
+	if m.settings.profile != nil {
+		view += fmt.Sprintf(`
+Load Profile : %s`, m.settings.profile)
+	}
+	if m.settings.simulator != nil {
+		view += fmt.Sprintf(`
+Seed         : %d
+Phase        : %s`,
+			m.settings.seed,
+			m.settings.simulator.Phase(),
+		)
+	}
+	return view
 }
 
-func (m model) statsView() string {
+func (m model) statsView(s runner.Stats) string {
 	duration := m.stats.GetDuration()
-	return fmt.Sprintf(`%s
+	view := fmt.Sprintf(`%s
 Duration       : %s
 Go Routines    : %d (max: %d)
 Memory         : %d KB (max: %d KB)`,
@@ -160,84 +302,364 @@ Memory         : %d KB (max: %d KB)`,
 		m.stats.GetCurrentMemoryKB(),
 		m.stats.GetMaxMemoryKB(),
 	)
+	if m.settings != nil && m.settings.profile != nil {
+		view += fmt.Sprintf(`
+Target Rate    : %.2f/s
+Achieved Rate  : %.2f/s
+In Flight      : %d
+Skipped        : %d`,
+			s.TargetRate,
+			s.AchievedRate,
+			s.InFlight,
+			s.Skipped,
+		)
+	}
+	if m.certs != nil && m.certs.current != nil {
+		cert := m.certs.current
+		view += fmt.Sprintf(`
+TLS Subject    : %s
+TLS Issuer     : %s
+TLS Expires    : %s`,
+			cert.Subject,
+			cert.Issuer,
+			cert.NotAfter.Format(time.DateTime),
+		)
+	}
+	return view
+}
+
+func (m model) familiesView(stats runner.Stats) string {
+	if !m.settings.dualStack || stats.Families == nil {
+		return ""
+	}
+	column := func(family git.IPFamily) string {
+		s := stats.Families.Lookup(family.String())
+		return fmt.Sprintf(`%s
+Succeeded : %d
+Failed    : %d
+Success   : %.1f%%
+Avg Time  : %s`,
+			m.styles.SectionTitle(family.String(), "#BBBB00"),
+			s.Success,
+			s.Fail,
+			s.SuccessRate(),
+			s.AverageDuration().Round(time.Millisecond),
+		)
+	}
+	return "\n" + lipgloss.JoinHorizontal(lipgloss.Top,
+		lipgloss.NewStyle().Width(30).Render(column(git.IPv4)),
+		column(git.IPv6),
+	)
+}
+
+func (m model) operationsView(stats runner.Stats) string {
+	if m.settings.workload == nil || stats.Operations == nil {
+		return ""
+	}
+	lines := []string{"", m.styles.SectionTitle("Operations", "#BBBB00")}
+	for _, o := range m.settings.workload.Operations {
+		s := stats.Operations.Lookup(string(o.Op))
+		lines = append(lines, fmt.Sprintf("%-11s %5.1f%% of mix  ok %-5d fail %-5d %5.1f%%  avg %s",
+			o.Op, m.settings.workload.Share(o.Op), s.Success, s.Fail, s.SuccessRate(), s.AverageDuration().Round(time.Millisecond)))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+func (m model) backendsView(stats runner.Stats) string {
+	if stats.Backends == nil {
+		return ""
+	}
+	backends := stats.Backends.Get()
+	if len(backends) == 0 || (len(backends) == 1 && !m.settings.pinned) {
+		return ""
+	}
+	lines := []string{"", m.styles.SectionTitle("Backends", "#BBBB00")}
+	for _, b := range backends {
+		lines = append(lines, fmt.Sprintf("%-39s ok %-5d fail %-5d %5.1f%%  avg %s",
+			b.Key, b.Success, b.Fail, b.SuccessRate(), b.AverageDuration().Round(time.Millisecond)))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
 }
 
-func (m model) resultsView() string {
-	return fmt.Sprintf(`%s Succeeded: %d
+func (m model) certWarningView() string {
+	warnings := m.certs.warnings(time.Now())
+	if len(warnings) == 0 {
+		return ""
+	}
+	style := lipgloss.NewStyle().Foreground(lipgloss.Color("#FFAA00")).Bold(true)
+	for i, w := range warnings {
+		warnings[i] = style.Render("⚠ " + w)
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, warnings...)
+}
+
+func (m model) resultsView(stats runner.Stats) string {
+	view := fmt.Sprintf(`%s Succeeded: %d
 %s Failed: %d`,
 		m.success.spinner.View(), m.success.count,
 		m.fail.spinner.View(), m.fail.count,
 	)
+	if m.settings.retry.MaxRetries > 0 {
+		r := stats.Retries
+		view += fmt.Sprintf(`
+First Try : %d ok / %d failed (%.1f%%)
+Final     : %d ok / %d failed (%.1f%%)
+Masked    : %d failures recovered by %d retries`,
+			r.FirstSuccess, r.FirstFail, r.FirstTrySuccessRate(),
+			r.FinalSuccess, r.FinalFail, r.FinalSuccessRate(),
+			r.Masked, r.Retries,
+		)
+	}
+	return view + windowsView(stats)
+}
+
+// windowsView compares recent results over each window with the lifetime
+// totals, so a fresh outage stands out after hours of running
+func windowsView(stats runner.Stats) string {
+	if len(stats.Windows) == 0 {
+		return ""
+	}
+	lines := []string{"", fmt.Sprintf("%-8s %-7s %-7s %8s %9s %8s %8s %8s", "Window", "ok", "fail", "success", "rate", "p50", "p90", "p99")}
+	for _, w := range stats.Windows {
+		lines = append(lines, fmt.Sprintf("%-8s %-7d %-7d %7.1f%% %7.2f/s %8s %8s %8s",
+			spanLabel(w.Span), w.Success, w.Fail, w.SuccessRate(), w.Throughput,
+			w.P50.Round(time.Millisecond), w.P90.Round(time.Millisecond), w.P99.Round(time.Millisecond)))
+	}
+	lines = append(lines, fmt.Sprintf("%-8s %-7d %-7d %7.1f%% %7.2f/s %8s %8s %8s",
+		"All", stats.Success, stats.Fail, stats.SuccessRate(), stats.Throughput(), "-", "-", "-"))
+	return "\n" + lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+// spanLabel renders a window span compactly, e.g. 5m rather than 5m0s
+func spanLabel(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return d.String()
+	}
 }
 
 func (m model) errView() string {
-	recentErrors := m.errorStats.GetRecentErrors()
-	if len(recentErrors) == 0 {
+	groups := m.errorStats.GetTopGroups(m.errorStats.maxRecent)
+	if len(groups) == 0 {
 		return ""
 	}
 
 	var errorDisplay []string
-	errorDisplay = append(errorDisplay, m.styles.SectionTitle("Recent Errors", "#BBBB00"))
+	errorDisplay = append(errorDisplay, m.styles.SectionTitle("Top Errors", "#BBBB00"))
 
-	// Show recent errors with timestamps
-	for i := len(recentErrors) - 1; i >= 0; i-- {
-		errInfo := recentErrors[i]
-		timeAgo := time.Since(errInfo.timestamp).Truncate(time.Second)
-		errMsg := errInfo.err.Error()
+	// Show the most frequent error groups with when they were first and last seen
+	for _, g := range groups {
+		lastSeen := time.Since(g.lastSeen).Truncate(time.Second)
+		firstSeen := time.Since(g.firstSeen).Truncate(time.Second)
+		errMsg := g.key
 		if len(errMsg) > 50 {
 			errMsg = errMsg[:47] + "..."
 		}
 		errorDisplay = append(errorDisplay,
-			fmt.Sprintf("%s ago: %s",
-				timeAgo,
-				lipgloss.NewStyle().Foreground(lipgloss.Color("#FF6666")).Render(errMsg)))
+			fmt.Sprintf("%4dx %s (last %s ago, first %s ago)",
+				g.count,
+				lipgloss.NewStyle().Foreground(lipgloss.Color("#FF6666")).Render(errMsg),
+				lastSeen,
+				firstSeen))
 	}
+	errorDisplay = append(errorDisplay,
+		lipgloss.NewStyle().Faint(true).Render("press e to browse the full error log"))
 
 	return "\n" + lipgloss.JoinVertical(lipgloss.Left, errorDisplay...) + "\n"
 }
 
-func Start(repo string, interval, timeout time.Duration, width int, demoMode bool, errorHistory int) error {
-	var f *os.File
-	var err error
+// checkCert tracks the server certificate, logging a warning when it
+// changes or is close to expiring
+func (m model) checkCert(a runner.Attempt) {
+	changed := m.certs.observe(a)
+	if m.settings.log == nil || a.Cert == nil {
+		return
+	}
+	if changed {
+		m.settings.log.Warn("tls certificate changed",
+			"target", m.settings.target,
+			"attempt", a.ID,
+			"old_subject", m.certs.previous.Subject,
+			"old_fingerprint", m.certs.previous.Fingerprint,
+			"subject", a.Cert.Subject,
+			"fingerprint", a.Cert.Fingerprint,
+		)
+	}
+	if m.certs.expiring(time.Now()) && m.certs.warnedExpiry != a.Cert.Fingerprint {
+		m.certs.warnedExpiry = a.Cert.Fingerprint
+		m.settings.log.Warn("tls certificate expiring",
+			"target", m.settings.target,
+			"subject", a.Cert.Subject,
+			"not_after", a.Cert.NotAfter,
+		)
+	}
+}
+
+// operation returns the name of the operation cfg runs
+func (cfg Config) operation() string {
+	switch {
+	case cfg.DemoMode:
+		return "demo"
+	case cfg.Operation == "":
+		return runner.DefaultOperation
+	default:
+		return cfg.Operation
+	}
+}
+
+// target returns the repository with any credentials hidden, for logs and
+// hooks
+func (cfg Config) target() string {
+	return git.RedactURL(cfg.Repo)
+}
 
-	// Only create log file for real mode, not demo mode
-	if !demoMode {
-		f, err = os.Create("gitter.log")
-		if err != nil {
-			return err
+// newRunner creates the runner for cfg, delivering attempts to results and
+// onResult
+func newRunner(cfg Config, results chan<- runner.Attempt, onResult func(runner.Attempt)) (*runner.Runner, *demo.Simulator, error) {
+	name := cfg.operation()
+	registry := cfg.Operations
+	if registry == nil {
+		registry = runner.DefaultRegistry
+	}
+	operation, err := registry.New(name, runner.OperationConfig{
+		Git:       cfg.Git,
+		DualStack: cfg.DualStack,
+		Seed:      cfg.Seed,
+		Scenario:  cfg.Scenario,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	var simulator *demo.Simulator
+	if d, ok := operation.(*runner.DemoCloneOperation); ok {
+		simulator = d.Simulator
+	}
+	if cfg.Hooks != nil {
+		next := onResult
+		onResult = func(a runner.Attempt) {
+			cfg.Hooks.Observe(a)
+			if next != nil {
+				next(a)
+			}
 		}
-		defer func() {
-			if closeErr := f.Close(); closeErr != nil {
-				// Log close error, but don't override main error
-				_, _ = fmt.Fprintf(os.Stderr, "Warning: failed to close log file: %v\n", closeErr)
+	}
+	for _, e := range cfg.Exports {
+		next := onResult
+		onResult = func(a runner.Attempt) {
+			e.Record(a)
+			if next != nil {
+				next(a)
+			}
+		}
+	}
+	if cfg.History != nil {
+		next := onResult
+		onResult = func(a runner.Attempt) {
+			cfg.History.Record(a)
+			if next != nil {
+				next(a)
 			}
-		}()
+		}
+	}
+	opts := runner.Options{
+		Repo:        cfg.Repo,
+		Interval:    cfg.Interval,
+		Timeout:     cfg.Timeout,
+		Retry:       cfg.Retry,
+		LoadProfile: cfg.LoadProfile,
+		MaxInFlight: cfg.MaxInFlight,
+		Workload:    cfg.Workload,
+		Results:     results,
+		OnResult:    onResult,
+		Log:         cfg.Log,
+	}
+	if cfg.Telemetry != nil {
+		opts.Instrument = cfg.Telemetry.Instrument
+	}
+	if cfg.StatsD != nil {
+		opts.Instrument = chainInstrument(opts.Instrument, cfg.StatsD.Instrument)
+	}
+	r, err := runner.New(operation, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, simulator, nil
+}
+
+// instrumentFunc has the signature of runner.Options.Instrument
+type instrumentFunc = func(context.Context, runner.Attempt) (context.Context, func(runner.Attempt))
+
+// chainInstrument returns an instrument function running first, if set,
+// then next, ending the attempt in the reverse order
+func chainInstrument(first, next instrumentFunc) instrumentFunc {
+	if first == nil {
+		return next
+	}
+	return func(ctx context.Context, a runner.Attempt) (context.Context, func(runner.Attempt)) {
+		ctx, endFirst := first(ctx, a)
+		ctx, endNext := next(ctx, a)
+		return ctx, func(a runner.Attempt) {
+			endNext(a)
+			endFirst(a)
+		}
 	}
+}
+
+// drainTimeout bounds waiting for attempts in flight once the program exits
+const drainTimeout = 10 * time.Second
 
+func Start(cfg Config) error {
 	// Create the channel for results
-	resultC := make(chan error)
+	resultC := make(chan runner.Attempt)
 
 	// Create new components using constructors
 	stats := NewAppStats()
-	errorStats := NewErrorStats(errorHistory)
-	styles := NewStyles(width)
-	cloneRunner := NewCloneRunner(demoMode, time.NewTicker(interval).C, repo, timeout, resultC)
+	errorStats := NewErrorStats(cfg.ErrorHistory)
+	styles := NewStyles(cfg.Width)
+
+	r, simulator, err := newRunner(cfg, resultC, nil)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runDone := make(chan struct{})
 
 	p := tea.NewProgram(model{
 		settings: &appSettings{
-			t:            time.NewTicker(interval),
-			repo:         repo,
-			timeout:      timeout,
-			interval:     interval,
-			log:          f,
-			demoMode:     demoMode,
-			width:        width,
-			errorHistory: errorHistory,
+			t:            time.NewTicker(cfg.Interval),
+			repo:         cfg.Repo,
+			target:       cfg.target(),
+			timeout:      cfg.Timeout,
+			interval:     cfg.Interval,
+			log:          cfg.Log,
+			demoMode:     cfg.DemoMode,
+			width:        cfg.Width,
+			errorHistory: cfg.ErrorHistory,
+			seed:         cfg.Seed,
+			simulator:    simulator,
+			pinned:       len(cfg.Git.Resolve) > 0 || cfg.Git.RoundRobin,
+			dualStack:    cfg.DualStack && !cfg.DemoMode,
+			retry:        cfg.Retry,
+			profile:      cfg.LoadProfile,
+			workload:     cfg.Workload,
+			operation:    cfg.operation(),
+			hooks:        cfg.Hooks,
 		},
-		stats:       stats,
-		errorStats:  errorStats,
-		styles:      styles,
-		cloneRunner: cloneRunner,
+		stats:      stats,
+		errorStats: errorStats,
+		styles:     styles,
+		runner:     r,
+		runCtx:     ctx,
+		runDone:    runDone,
+		browser:    newErrorBrowser(styles),
+		certs:      newCertMonitor(cfg.CertExpiryWarning),
+		alerts:     alert.NewEvaluator(cfg.Alerts),
 		success: result{
 			spinner: spinner.New(spinner.WithSpinner(spinner.Dot), spinner.WithStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("#00FF00")))),
 			count:   0,
@@ -251,5 +673,26 @@ func Start(repo string, interval, timeout time.Duration, width int, demoMode boo
 	if _, err := p.Run(); err != nil {
 		return err
 	}
+	// Stop the runner and let the attempts in flight finish, so they are
+	// recorded before the caller closes the exports
+	cancel()
+	select {
+	case <-runDone:
+	case <-time.After(drainTimeout):
+	}
+	waitHooks(cfg)
 	return nil
 }
+
+// waitHooks gives the events fired during the run up to drainTimeout to be
+// delivered
+func waitHooks(cfg Config) {
+	if cfg.Hooks == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	if err := cfg.Hooks.Wait(ctx); err != nil && cfg.Log != nil {
+		cfg.Log.Warn("hook events not delivered before exit", "error", err.Error())
+	}
+}
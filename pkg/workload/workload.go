@@ -0,0 +1,162 @@
+// Package workload describes weighted mixes of git operations and
+// repositories, so a run can resemble production traffic rather than a
+// stream of identical clones.
+package workload
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kloudyuk/gitter/pkg/git"
+)
+
+// OpWeight gives an operation's share of the workload
+type OpWeight struct {
+	Op     git.Operation `json:"op"`
+	Weight float64       `json:"weight"`
+}
+
+// RepoWeight gives a repository's share of the workload
+type RepoWeight struct {
+	URL    string  `json:"url"`
+	Weight float64 `json:"weight"`
+}
+
+// Workload picks an operation and a repository for each attempt, each in
+// proportion to its weight. Weights are relative and need not add up to 100.
+type Workload struct {
+	Operations []OpWeight `json:"operations"`
+	// Repos is optional; without it the run's repository is used
+	Repos []RepoWeight `json:"repos,omitempty"`
+}
+
+// Load reads a JSON workload file
+func Load(path string) (*Workload, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var w Workload
+	if err := json.Unmarshal(data, &w); err != nil {
+		return nil, fmt.Errorf("parsing workload %s: %w", path, err)
+	}
+	if err := w.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid workload %s: %w", path, err)
+	}
+	return &w, nil
+}
+
+// Parse reads an inline operation mix such as
+// "ls-remote=70,clone=25,full-clone=5"
+func Parse(s string) (*Workload, error) {
+	var w Workload
+	for _, part := range strings.Split(s, ",") {
+		op, weight, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid workload entry %q, must be operation=weight", part)
+		}
+		n, err := strconv.ParseFloat(strings.TrimSpace(weight), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid weight in workload entry %q", part)
+		}
+		w.Operations = append(w.Operations, OpWeight{Op: git.Operation(strings.TrimSpace(op)), Weight: n})
+	}
+	if err := w.Validate(); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// Validate checks every operation is known and the weights are usable
+func (w *Workload) Validate() error {
+	if len(w.Operations) == 0 {
+		return errors.New("workload must have at least one operation")
+	}
+	var total float64
+	for _, o := range w.Operations {
+		if !slices.Contains(git.Operations, o.Op) {
+			return fmt.Errorf("unknown operation %q", o.Op)
+		}
+		if !validWeight(o.Weight) {
+			return fmt.Errorf("weight for %s must be a non-negative number, got %v", o.Op, o.Weight)
+		}
+		total += o.Weight
+	}
+	if total == 0 {
+		return errors.New("operation weights must not all be zero")
+	}
+
+	total = 0
+	for _, r := range w.Repos {
+		if r.URL == "" {
+			return errors.New("repository url must not be empty")
+		}
+		if !validWeight(r.Weight) {
+			return fmt.Errorf("weight for %s must be a non-negative number, got %v", r.URL, r.Weight)
+		}
+		total += r.Weight
+	}
+	if len(w.Repos) > 0 && total == 0 {
+		return errors.New("repository weights must not all be zero")
+	}
+	return nil
+}
+
+// validWeight reports whether w is a finite, non-negative weight
+func validWeight(w float64) bool {
+	return w >= 0 && !math.IsInf(w, 0)
+}
+
+// Share returns op's percentage of the operation mix
+func (w *Workload) Share(op git.Operation) float64 {
+	var total, weight float64
+	for _, o := range w.Operations {
+		total += o.Weight
+		if o.Op == op {
+			weight += o.Weight
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return weight / total * 100
+}
+
+// Pick chooses the operation and repository for the next attempt. The
+// repository is empty when the workload doesn't list any.
+func (w *Workload) Pick() (git.Operation, string) {
+	op := w.Operations[pick(len(w.Operations), func(i int) float64 { return w.Operations[i].Weight })].Op
+	if len(w.Repos) == 0 {
+		return op, ""
+	}
+	return op, w.Repos[pick(len(w.Repos), func(i int) float64 { return w.Repos[i].Weight })].URL
+}
+
+// pick chooses an index in proportion to its weight
+func pick(n int, weight func(int) float64) int {
+	var total float64
+	for i := range n {
+		total += weight(i)
+	}
+	roll := rand.Float64() * total
+	for i := range n {
+		if roll < weight(i) {
+			return i
+		}
+		roll -= weight(i)
+	}
+	// Rounding can leave roll just above the last weight
+	for i := n - 1; i > 0; i-- {
+		if weight(i) > 0 {
+			return i
+		}
+	}
+	return 0
+}
@@ -0,0 +1,97 @@
+package workload
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kloudyuk/gitter/pkg/git"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantOps int
+		wantErr bool
+	}{
+		{"ls-remote=70,clone=25,full-clone=5", 3, false},
+		{"push=1", 1, false},
+		{"clone=1,fetch=0", 2, false},
+		{"clone", 0, true},
+		{"clone=lots", 0, true},
+		{"gc=1", 0, true},
+		{"clone=-1,fetch=2", 0, true},
+		{"clone=NaN,fetch=2", 0, true},
+		{"clone=Inf,fetch=2", 0, true},
+		{"clone=0", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			w, err := Parse(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if err == nil && len(w.Operations) != tt.wantOps {
+				t.Errorf("Expected %d operations, got %d", tt.wantOps, len(w.Operations))
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "workload.json")
+	data := `{
+		"operations": [{"op": "ls-remote", "weight": 70}, {"op": "clone", "weight": 30}],
+		"repos": [{"url": "https://example.com/a.git", "weight": 1}, {"url": "https://example.com/b.git", "weight": 3}]
+	}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("Failed to write workload: %v", err)
+	}
+
+	w, err := Load(path)
+	if err != nil {
+		t.Fatalf("Expected workload to load, got %v", err)
+	}
+	if len(w.Operations) != 2 || len(w.Repos) != 2 {
+		t.Errorf("Expected 2 operations and 2 repos, got %d and %d", len(w.Operations), len(w.Repos))
+	}
+
+	if err := os.WriteFile(path, []byte(`{"operations": [{"op": "rebase", "weight": 1}]}`), 0o644); err != nil {
+		t.Fatalf("Failed to write workload: %v", err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Expected unknown operation to be rejected, got nil")
+	}
+}
+
+func TestPick(t *testing.T) {
+	w := &Workload{
+		Operations: []OpWeight{{git.OpLsRemote, 70}, {git.OpClone, 25}, {git.OpPush, 5}, {git.OpFetch, 0}},
+		Repos:      []RepoWeight{{"a", 1}, {"b", 3}},
+	}
+
+	const n = 20000
+	ops := map[git.Operation]int{}
+	repos := map[string]int{}
+	for range n {
+		op, repo := w.Pick()
+		ops[op]++
+		repos[repo]++
+	}
+
+	for _, o := range w.Operations {
+		got := float64(ops[o.Op]) / n * 100
+		if math.Abs(got-o.Weight) > 2 {
+			t.Errorf("Expected %s about %v%% of the time, got %.1f%%", o.Op, o.Weight, got)
+		}
+	}
+	if got := float64(repos["b"]) / n; math.Abs(got-0.75) > 0.02 {
+		t.Errorf("Expected repo b about 75%% of the time, got %.1f%%", got*100)
+	}
+
+	if _, repo := (&Workload{Operations: w.Operations}).Pick(); repo != "" {
+		t.Errorf("Expected no repo when none are listed, got '%s'", repo)
+	}
+}